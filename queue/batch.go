@@ -0,0 +1,93 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"unsafe"
+)
+
+// enqueueBatch enqueues the elements of xs into q in order.
+// Queues which are not batchQueues get one element at a time.
+func enqueueBatch( q interfaceQueue, xs []interface{} ) {
+	if bq, ok := q.( batchQueue ); ok {
+		bq.enqueueBatch( xs )
+		return
+	}
+	for _, x := range xs {
+		q.enqueue( x )
+	}
+}
+
+// dequeueBatch dequeues up to len( dst ) elements from q into dst
+// and returns the number of dequeued elements.
+// Queues which are not batchQueues are asked for one element at a time.
+func dequeueBatch( q interfaceQueue, dst []interface{} ) int {
+	if bq, ok := q.( batchQueue ); ok {
+		return bq.dequeueBatch( dst )
+	}
+	for i := range dst {
+		x, ok := q.dequeue()
+		if !ok {
+			return i
+		}
+		dst[i] = x
+	}
+
+	return len( dst )
+}
+
+// tryEnqueueBatch enqueues the leading elements of xs into q
+// as long as q is not full,
+// and returns the number of enqueued elements.
+// It never blocks.
+// Queues which are not boundedBatchQueues get one element at a time.
+func tryEnqueueBatch( q interfaceQueue, xs []interface{} ) int {
+	if bq, ok := q.( boundedBatchQueue ); ok {
+		return bq.tryEnqueueBatch( xs )
+	}
+	for i, x := range xs {
+		if !tryEnqueue( q, x ) {
+			return i
+		}
+	}
+
+	return len( xs )
+}
+
+// newNodeChain creates a linked list of nodes holding the elements of xs.
+// The list must not be empty.
+func newNodeChain( xs []interface{} ) ( first, last *listNode ) {
+	first = &listNode{
+		value: xs[0],
+	}
+	last = first
+	for _, x := range xs[1:] {
+		node := &listNode{
+			value: x,
+		}
+		last.next = unsafe.Pointer( node )
+		last = node
+	}
+
+	return
+}
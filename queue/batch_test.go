@@ -0,0 +1,297 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// batchTestQueue is used to test batch operations.
+type batchTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	EnqueueBatch func( []int ) `queue:"enqueueBatch"`
+	DequeueBatch func( []int ) int `queue:"dequeueBatch"`
+}
+
+// variadicBatchTestQueue is used to test the variadic batch enqueue.
+type variadicBatchTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	EnqueueBatch func( ...int ) `queue:"enqueueBatch"`
+	DequeueBatch func( []int ) int `queue:"dequeueBatch"`
+}
+
+func TestBatch( t *testing.T ) {
+	const n = 100
+	for _, config := range allConfigs( 100 ) {
+		var q batchTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		xs := make( []int, n )
+		for i := range xs {
+			xs[i] = i
+		}
+		q.EnqueueBatch( nil )
+		q.EnqueueBatch( xs[:n/2] )
+		q.Enqueue( n / 2 )
+		q.EnqueueBatch( xs[n/2+1:] )
+		dst := make( []int, n / 3 )
+		next := 0
+		for next < n {
+			m := q.DequeueBatch( dst )
+			if m == 0 {
+				t.Fatalf( "Batch dequeue fails on non-empty queue with flags %x", config.Flags )
+			}
+			for _, x := range dst[:m] {
+				if x != next {
+					t.Errorf( "Batch dequeue returned %d instead of %d with flags %x", x, next, config.Flags )
+				}
+				next++
+			}
+		}
+		if m := q.DequeueBatch( dst ); m != 0 {
+			t.Errorf( "Batch dequeue from empty queue returned %d elements with flags %x", m, config.Flags )
+		}
+		q.EnqueueBatch( []int{ 1, 2 } )
+		if m := q.DequeueBatch( dst ); m != 2 {
+			t.Errorf( "Partial batch dequeue returned %d elements with flags %x", m, config.Flags )
+		}
+		if x, ok := q.Dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue with flags %x: %d", config.Flags, x )
+		}
+	}
+}
+
+func TestBatchVariadic( t *testing.T ) {
+	var q variadicBatchTestQueue
+	if err := Make( &q, DefaultConfig() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.EnqueueBatch( 1, 2, 3 )
+	dst := make( []int, 4 )
+	if m := q.DequeueBatch( dst ); ( m != 3 ) || ( dst[0] != 1 ) || ( dst[1] != 2 ) || ( dst[2] != 3 ) {
+		t.Errorf( "Batch dequeue returned %v", dst[:m] )
+	}
+}
+
+func TestBatchBoundedFull( t *testing.T ) {
+	var q batchTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 3 ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != ErrFull {
+				t.Errorf( "Overfull batch enqueue recovered %v", r )
+			}
+		}()
+		q.EnqueueBatch( []int{ 1, 2, 3, 4 } )
+	}()
+	dst := make( []int, 4 )
+	if m := q.DequeueBatch( dst ); m != 3 {
+		t.Errorf( "%d elements of an overfull batch enqueued, expected 3", m )
+	}
+}
+
+// batchCountingQueue counts the operations reaching a locked queue,
+// each of which takes the lock once.
+type batchCountingQueue struct {
+	*lockedQueue
+	ops int
+}
+
+func ( q *batchCountingQueue ) enqueue( x interface{} ) {
+	q.ops++
+	q.lockedQueue.enqueue( x )
+}
+
+func ( q *batchCountingQueue ) tryEnqueue( x interface{} ) bool {
+	q.ops++
+	return q.lockedQueue.tryEnqueue( x )
+}
+
+func ( q *batchCountingQueue ) dequeue() ( interface{}, bool ) {
+	q.ops++
+	return q.lockedQueue.dequeue()
+}
+
+func ( q *batchCountingQueue ) enqueueBatch( xs []interface{} ) {
+	q.ops++
+	q.lockedQueue.enqueueBatch( xs )
+}
+
+func ( q *batchCountingQueue ) tryEnqueueBatch( xs []interface{} ) int {
+	q.ops++
+	return q.lockedQueue.tryEnqueueBatch( xs )
+}
+
+func ( q *batchCountingQueue ) dequeueBatch( dst []interface{} ) int {
+	q.ops++
+	return q.lockedQueue.dequeueBatch( dst )
+}
+
+func TestBatchWrappers( t *testing.T ) {
+	const limit = 10
+	wrappers := []struct{
+		name string
+		wrap func( q *batchCountingQueue ) interfaceQueue
+	}{
+		{ "bounded", func( q *batchCountingQueue ) interfaceQueue {
+			return newOverflowQueue( q, OverflowBlock )
+		} },
+		{ "flush", func( q *batchCountingQueue ) interfaceQueue {
+			return newFlushQueue( q, true )
+		} },
+		{ "bounded flush", func( q *batchCountingQueue ) interfaceQueue {
+			return newOverflowQueue( newFlushQueue( q, true ), OverflowBlock )
+		} },
+	}
+	xs := make( []interface{}, limit )
+	for i := range xs {
+		xs[i] = i
+	}
+	for _, wrapper := range wrappers {
+		cq := &batchCountingQueue{
+			lockedQueue: newLockedQueue( 1, maxSegmentSize, DefaultShrinkRatio ),
+		}
+		cq.limit = limit
+		q := wrapper.wrap( cq )
+		enqueueBatch( q, xs )
+		if cq.ops != 1 {
+			t.Errorf( "%s: Batch enqueue took %d operations", wrapper.name, cq.ops )
+		}
+		dst := make( []interface{}, limit + 1 )
+		if n := dequeueBatch( q, dst ); n != limit {
+			t.Errorf( "%s: %d elements dequeued, expected %d", wrapper.name, n, limit )
+		}
+		for i := 0; i < limit; i++ {
+			if dst[i] != i {
+				t.Errorf( "%s: Dequeued %v, expected %d", wrapper.name, dst[i], i )
+			}
+		}
+		if cq.ops != 2 {
+			t.Errorf( "%s: Batch dequeue took %d operations", wrapper.name, cq.ops - 1 )
+		}
+	}
+	// The overflow policy applies to the elements which do not fit.
+	cq := &batchCountingQueue{
+		lockedQueue: newLockedQueue( 1, maxSegmentSize, DefaultShrinkRatio ),
+	}
+	cq.limit = limit - 3
+	oq := newOverflowQueue( cq, OverflowDropNewest )
+	oq.enqueueBatch( xs )
+	if d := oq.discardedCount(); d != 3 {
+		t.Errorf( "%d elements discarded, expected 3", d )
+	}
+	if n := cq.count(); n != limit - 3 {
+		t.Errorf( "%d elements enqueued, expected %d", n, limit - 3 )
+	}
+}
+
+func TestBatchParallel( t *testing.T ) {
+	const writers = 4
+	const readers = 4
+	const iterations = 200
+	const batchSize = 10
+	for _, config := range filterConfigs( allConfigs( 100 ), concurrentConfig, unboundedConfig ) {
+		var q batchTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		w, r := writers, readers
+		if ( config.Flags & FMultiWriter ) == 0 {
+			w = 1
+		}
+		if ( config.Flags & FMultiReader ) == 0 {
+			r = 1
+		}
+		total := int32( w * iterations * batchSize )
+		seen := make( []int32, total )
+		var received int32
+		var wg sync.WaitGroup
+		wg.Add( w + r )
+		for id := 0; id < w; id++ {
+			go func( id int ) {
+				defer wg.Done()
+				xs := make( []int, batchSize )
+				for i := 0; i < iterations; i++ {
+					for j := range xs {
+						xs[j] = ( id * iterations + i ) * batchSize + j
+					}
+					q.EnqueueBatch( xs )
+				}
+			}( id )
+		}
+		for id := 0; id < r; id++ {
+			go func() {
+				defer wg.Done()
+				dst := make( []int, batchSize / 3 )
+				for atomic.LoadInt32( &received ) < total {
+					m := q.DequeueBatch( dst )
+					for _, x := range dst[:m] {
+						atomic.AddInt32( &seen[x], 1 )
+					}
+					atomic.AddInt32( &received, int32( m ) )
+				}
+			}()
+		}
+		wg.Wait()
+		for x := range seen {
+			if seen[x] != 1 {
+				t.Errorf( "Element %d dequeued %d times with flags %x", x, seen[x], config.Flags )
+			}
+		}
+	}
+}
+
+func BenchmarkLockedQueueSingle( b *testing.B ) {
+	var q batchTestQueue
+	if err := Make( &q, DefaultConfig().Implementation( "locked" ) ); err != nil {
+		b.Fatalf( "Make failed: %s", err )
+	}
+	for i := 0; i < b.N; i++ {
+		q.Enqueue( i )
+	}
+	for i := 0; i < b.N; i++ {
+		q.Dequeue()
+	}
+}
+
+func BenchmarkLockedQueueBatch( b *testing.B ) {
+	const batchSize = 64
+	var q batchTestQueue
+	if err := Make( &q, DefaultConfig().Implementation( "locked" ) ); err != nil {
+		b.Fatalf( "Make failed: %s", err )
+	}
+	xs := make( []int, batchSize )
+	for i := 0; i < b.N; i += batchSize {
+		q.EnqueueBatch( xs )
+	}
+	for i := 0; i < b.N; i += batchSize {
+		q.DequeueBatch( xs )
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync/atomic"
+)
+
+// boundedCell is a single slot in the ring buffer of a boundedQueue.
+type boundedCell struct {
+	// sequence tells readers and writers whose turn it is for this cell.
+	// It must only be accessed atomically.
+	sequence uint64
+	value interface{}
+}
+
+// boundedQueue is a bounded, array-based queue for multiple readers and
+// multiple writers in the style of Dmitry Vyukov's bounded MPMC queue.
+// Each cell carries a sequence number.
+// A writer at position pos may fill a cell whose sequence number is 2 * pos,
+// and a reader at position pos may empty a cell whose sequence number
+// is 2 * pos + 1.
+// Doubling the positions keeps the sequence number of a filled cell distinct
+// from that of a cell ready for the next round of writers
+// even if the queue holds only a single element.
+// Readers and writers claim positions with compare-and-swap,
+// so they contend only among themselves,
+// and only on the respective position counter.
+type boundedQueue struct {
+	buffer []boundedCell
+	size uint64
+	_ [cacheLineSize]byte
+	// enqueuePos is the next position to write to.
+	// It must only be accessed atomically.
+	enqueuePos uint64
+	_ [cacheLineSize]byte
+	// dequeuePos is the next position to read from.
+	// It must only be accessed atomically.
+	dequeuePos uint64
+	_ [cacheLineSize]byte
+}
+
+// newBoundedQueue creates a new bounded queue which can hold up to limit
+// elements. The limit must be at least 1.
+func newBoundedQueue( limit int ) *boundedQueue {
+	q := &boundedQueue{
+		buffer: make( []boundedCell, limit ),
+		size: uint64( limit ),
+	}
+	for i := range q.buffer {
+		q.buffer[i].sequence = 2 * uint64( i )
+	}
+
+	return q
+}
+
+// tryEnqueue enqueues x unless the queue is full.
+// The return value indicates whether x has been enqueued.
+func ( q *boundedQueue ) tryEnqueue( x interface{} ) bool {
+	pos := atomic.LoadUint64( &q.enqueuePos )
+	for {
+		cell := &q.buffer[pos % q.size]
+		seq := atomic.LoadUint64( &cell.sequence )
+		diff := int64( seq - 2 * pos )
+		if diff == 0 {
+			if atomic.CompareAndSwapUint64( &q.enqueuePos, pos, pos + 1 ) {
+				cell.value = x
+				atomic.StoreUint64( &cell.sequence, 2 * pos + 1 )
+				return true
+			}
+		} else if diff < 0 {
+			// cell still holds an element from the previous round
+			return false
+		}
+		pos = atomic.LoadUint64( &q.enqueuePos )
+	}
+}
+
+// count returns the number of claimed positions.
+// Elements still being written or read are included.
+func ( q *boundedQueue ) count() int {
+	dequeuePos := atomic.LoadUint64( &q.dequeuePos )
+	enqueuePos := atomic.LoadUint64( &q.enqueuePos )
+	n := enqueuePos - dequeuePos
+	if int64( n ) < 0 {
+		return 0
+	}
+	if n > q.size {
+		return int( q.size )
+	}
+
+	return int( n )
+}
+
+func ( q *boundedQueue ) capacity() int {
+	return int( q.size )
+}
+
+func ( q *boundedQueue ) enqueue( x interface{} ) {
+	if !q.tryEnqueue( x ) {
+		panic( ErrFull )
+	}
+}
+
+func ( q *boundedQueue ) dequeue() ( x interface{}, ok bool ) {
+	pos := atomic.LoadUint64( &q.dequeuePos )
+	for {
+		cell := &q.buffer[pos % q.size]
+		seq := atomic.LoadUint64( &cell.sequence )
+		diff := int64( seq - ( 2 * pos + 1 ) )
+		if diff == 0 {
+			if atomic.CompareAndSwapUint64( &q.dequeuePos, pos, pos + 1 ) {
+				x = cell.value
+				cell.value = nil
+				ok = true
+				atomic.StoreUint64( &cell.sequence, 2 * ( pos + q.size ) )
+				return
+			}
+		} else if diff < 0 {
+			// cell has not been filled yet
+			ok = false
+			return
+		}
+		pos = atomic.LoadUint64( &q.dequeuePos )
+	}
+}
+
+// boundedQueueFactory implements factory for boundedQueue
+type boundedQueueFactory struct {
+	limit int
+	overflow OverflowPolicy
+	bq *boundedQueue
+	oq *overflowQueue
+}
+
+func ( bqf *boundedQueueFactory ) prepare() {
+	bqf.bq = newBoundedQueue( bqf.limit )
+	bqf.oq = newOverflowQueue( bqf.bq, bqf.overflow )
+}
+
+func ( bqf *boundedQueueFactory ) commit() {
+	// empty
+}
+
+func ( bqf *boundedQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( bqf.oq, methodType )
+}
+
+func ( bqf *boundedQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( bqf.oq, methodType )
+}
+
+func ( bqf *boundedQueueFactory ) queue() interfaceQueue {
+	return bqf.oq
+}
+
+func ( bqf *boundedQueueFactory ) reset() {
+	bqf.bq = nil
+	bqf.oq = nil
+}
+
+// newBoundedQueueFactory creates a factory for bounded queues
+// with multiple readers and multiple writers.
+// The buffer is allocated in full up front,
+// so limit is both the initial capacity and the capacity limit.
+// Values too small will be corrected.
+// The overflow policy determines the behaviour when the queue is full.
+func newBoundedQueueFactory( limit int, overflow OverflowPolicy ) factory {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &boundedQueueFactory{
+		limit: limit,
+		overflow: overflow,
+		bq: nil,
+		oq: nil,
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"sync/atomic"
+)
+
+// closeQueue wraps a queue so that it can be closed.
+// After the queue has been closed, enqueueing fails with ErrClosed,
+// while the remaining elements can still be dequeued.
+type closeQueue struct {
+	queue interfaceQueue
+	// closed is 1 once the queue has been closed, 0 before.
+	// It must only be accessed atomically.
+	closed int32
+	// pending is the number of enqueue operations in progress.
+	// It must only be accessed atomically.
+	pending int32
+	// done is closed when the queue is closed.
+	done chan struct{}
+	// onClose holds functions to be called when the queue is closed,
+	// and again whenever the last pending enqueue operation
+	// finishes after that.
+	// They must be added before the queue is used.
+	onClose []func()
+}
+
+func newCloseQueue( queue interfaceQueue ) *closeQueue {
+	return &closeQueue{
+		queue: queue,
+		done: make( chan struct{} ),
+	}
+}
+
+// leave ends a pending enqueue operation.
+// If the queue has been closed and no more operations are pending,
+// the onClose functions are called,
+// so that waiting readers can tell that the queue has been drained.
+func ( q *closeQueue ) leave() {
+	if ( atomic.AddInt32( &q.pending, -1 ) == 0 ) && ( atomic.LoadInt32( &q.closed ) != 0 ) {
+		for _, f := range q.onClose {
+			f()
+		}
+	}
+}
+
+// enqueue enqueues x unless the queue has been closed,
+// in which case it panics with ErrClosed.
+// Enqueue operations announce themselves in pending before checking
+// whether the queue is closed.
+// This way, a reader which finds the queue closed and no enqueue operations
+// pending can be sure that no further elements will appear.
+func ( q *closeQueue ) enqueue( x interface{} ) {
+	atomic.AddInt32( &q.pending, 1 )
+	defer q.leave()
+	if atomic.LoadInt32( &q.closed ) != 0 {
+		panic( ErrClosed )
+	}
+	q.queue.enqueue( x )
+}
+
+// tryEnqueue works like enqueue,
+// but returns false instead of panicking.
+func ( q *closeQueue ) tryEnqueue( x interface{} ) bool {
+	atomic.AddInt32( &q.pending, 1 )
+	defer q.leave()
+	if atomic.LoadInt32( &q.closed ) != 0 {
+		return false
+	}
+	return tryEnqueue( q.queue, x )
+}
+
+// enqueueCtx works like enqueue,
+// but returns errors instead of panicking,
+// and may wait while the queue is full (see waitingEnqueue).
+// A waiting writer remains pending until it is woken up by close.
+func ( q *closeQueue ) enqueueCtx( ctx context.Context, x interface{} ) error {
+	atomic.AddInt32( &q.pending, 1 )
+	defer q.leave()
+	if atomic.LoadInt32( &q.closed ) != 0 {
+		return ErrClosed
+	}
+	return enqueueCtx( ctx, q.queue, x )
+}
+
+func ( q *closeQueue ) enqueueBatch( xs []interface{} ) {
+	atomic.AddInt32( &q.pending, 1 )
+	defer q.leave()
+	if atomic.LoadInt32( &q.closed ) != 0 {
+		panic( ErrClosed )
+	}
+	enqueueBatch( q.queue, xs )
+}
+
+func ( q *closeQueue ) dequeueBatch( dst []interface{} ) int {
+	return dequeueBatch( q.queue, dst )
+}
+
+func ( q *closeQueue ) dequeue() ( x interface{}, ok bool ) {
+	return q.queue.dequeue()
+}
+
+func ( q *closeQueue ) unwrap() interfaceQueue {
+	return q.queue
+}
+
+// dequeueOrClosed dequeues an element.
+// If the queue is empty, drained reports whether the queue has been closed
+// and no more elements will appear.
+func ( q *closeQueue ) dequeueOrClosed() ( x interface{}, ok, drained bool ) {
+	if x, ok = q.queue.dequeue(); ok {
+		return
+	}
+	if ( atomic.LoadInt32( &q.closed ) == 0 ) || ( atomic.LoadInt32( &q.pending ) != 0 ) {
+		return
+	}
+	if x, ok = q.queue.dequeue(); ok {
+		return
+	}
+	drained = true
+
+	return
+}
+
+// close closes the queue and wakes up all goroutines waiting on it.
+// Closing a closed queue has no effect.
+func ( q *closeQueue ) close() {
+	if atomic.CompareAndSwapInt32( &q.closed, 0, 1 ) {
+		close( q.done )
+		for _, f := range q.onClose {
+			f()
+		}
+	}
+}
+
+// tryDequeue dequeues an element from q without waiting.
+// If q is empty, err is ErrClosed if q has been closed and drained,
+// and ErrEmpty otherwise.
+func tryDequeue( q interfaceQueue ) ( x interface{}, err error ) {
+	if wq, ok := q.( *waitQueue ); ok {
+		q = wq.queue
+	}
+	if cq, ok := q.( *closeQueue ); ok {
+		x, ok, drained := cq.dequeueOrClosed()
+		switch {
+		case ok:
+			return x, nil
+		case drained:
+			return nil, ErrClosed
+		default:
+			return nil, ErrEmpty
+		}
+	}
+	if x, ok := q.dequeue(); ok {
+		return x, nil
+	}
+
+	return nil, ErrEmpty
+}
@@ -0,0 +1,269 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closeTestQueue is used to test closing.
+type closeTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	EnqueueErr func( int ) error `queue:"enqueue"`
+	TryEnqueue func( int ) bool `queue:"tryEnqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueErr func() ( int, error ) `queue:"dequeue"`
+	DequeueWait func() ( int, bool ) `queue:"dequeueWait"`
+	DequeueCtx func( context.Context ) ( int, error ) `queue:"dequeueCtx"`
+	DequeueTimeout func( time.Duration ) ( int, bool ) `queue:"dequeueTimeout"`
+	Close func() `queue:"close"`
+}
+
+func TestClose( t *testing.T ) {
+	for _, config := range allConfigs( 8 ) {
+		var q closeTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		if _, err := q.DequeueErr(); err != ErrEmpty {
+			t.Errorf( "Dequeue from empty open queue reports %v instead of ErrEmpty", err )
+		}
+		q.Enqueue( 1 )
+		if err := q.EnqueueErr( 2 ); err != nil {
+			t.Errorf( "Enqueue into open queue failed: %s", err )
+		}
+		q.Enqueue( 3 )
+		q.Enqueue( 4 )
+		q.Close()
+		q.Close()
+		func() {
+			defer func() {
+				if r := recover(); r != ErrClosed {
+					t.Errorf( "Enqueue into closed queue did not panic with ErrClosed: %v", r )
+				}
+			}()
+			q.Enqueue( 5 )
+		}()
+		if err := q.EnqueueErr( 5 ); err != ErrClosed {
+			t.Errorf( "Enqueue into closed queue reports %v instead of ErrClosed", err )
+		}
+		// Remaining elements can still be dequeued
+		if x, ok := q.Dequeue(); !ok || ( x != 1 ) {
+			t.Errorf( "Dequeue returned %d, %t instead of 1, true", x, ok )
+		}
+		if x, err := q.DequeueErr(); ( err != nil ) || ( x != 2 ) {
+			t.Errorf( "Dequeue returned %d, %v instead of 2, nil", x, err )
+		}
+		if x, ok := q.DequeueWait(); !ok || ( x != 3 ) {
+			t.Errorf( "DequeueWait returned %d, %t instead of 3, true", x, ok )
+		}
+		if x, err := q.DequeueCtx( context.Background() ); ( err != nil ) || ( x != 4 ) {
+			t.Errorf( "DequeueCtx returned %d, %v instead of 4, nil", x, err )
+		}
+		// Then the closed state is reported
+		if _, ok := q.Dequeue(); ok {
+			t.Error( "Dequeue succeeds on drained queue" )
+		}
+		if _, err := q.DequeueErr(); err != ErrClosed {
+			t.Errorf( "Dequeue from drained queue reports %v instead of ErrClosed", err )
+		}
+		if _, ok := q.DequeueWait(); ok {
+			t.Error( "DequeueWait succeeds on drained queue" )
+		}
+		if _, err := q.DequeueCtx( context.Background() ); err != ErrClosed {
+			t.Errorf( "DequeueCtx on drained queue reports %v instead of ErrClosed", err )
+		}
+		start := time.Now()
+		if _, ok := q.DequeueTimeout( time.Hour ); ok {
+			t.Error( "DequeueTimeout succeeds on drained queue" )
+		}
+		if time.Since( start ) > time.Second {
+			t.Error( "DequeueTimeout waits on drained queue" )
+		}
+	}
+}
+
+func TestCloseWakesReaders( t *testing.T ) {
+	for _, config := range filterConfigs( allConfigs( 8 ), concurrentConfig ) {
+		var q closeTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		done := make( chan bool )
+		go func() {
+			_, ok := q.DequeueWait()
+			done <- ok
+		}()
+		time.Sleep( 10 * time.Millisecond )
+		q.Close()
+		select {
+		case ok := <-done:
+			if ok {
+				t.Error( "DequeueWait succeeds on closed empty queue" )
+			}
+		case <-time.After( 5 * time.Second ):
+			t.Fatalf( "DequeueWait not woken up by Close with flags %x", config.Flags )
+		}
+	}
+}
+
+func TestCloseWaitsForPending( t *testing.T ) {
+	cq := newCloseQueue( newLockedQueue( DefaultInitialCapacity, maxSegmentSize, DefaultShrinkRatio ) )
+	wq := newWaitQueue( cq )
+	// Simulate an enqueue operation in progress while the queue is closed.
+	atomic.AddInt32( &cq.pending, 1 )
+	cq.close()
+	result := make( chan error, 1 )
+	go func() {
+		ctx, cancel := context.WithTimeout( context.Background(), 5 * time.Second )
+		defer cancel()
+		_, err := wq.dequeueCtx( ctx )
+		result <- err
+	}()
+	time.Sleep( 10 * time.Millisecond )
+	select {
+	case err := <-result:
+		t.Fatalf( "Reader returned %v while an enqueue operation is pending", err )
+	default:
+	}
+	cq.leave()
+	if err := <-result; err != ErrClosed {
+		t.Errorf( "Reader returned %v instead of ErrClosed after pending enqueue finished", err )
+	}
+}
+
+func TestCloseWakesWriters( t *testing.T ) {
+	var q closeTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 1 ).Overflow( OverflowBlock ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.Enqueue( 1 )
+	done := make( chan error )
+	go func() {
+		done <- q.EnqueueErr( 2 )
+	}()
+	time.Sleep( 10 * time.Millisecond )
+	q.Close()
+	select {
+	case err := <-done:
+		if err != ErrClosed {
+			t.Errorf( "Blocked enqueue reports %v instead of ErrClosed", err )
+		}
+	case <-time.After( 5 * time.Second ):
+		t.Fatal( "Blocked enqueue not woken up by Close" )
+	}
+	if x, ok := q.DequeueWait(); !ok || ( x != 1 ) {
+		t.Errorf( "DequeueWait returned %d, %t instead of 1, true", x, ok )
+	}
+	if _, ok := q.DequeueWait(); ok {
+		t.Error( "DequeueWait succeeds on drained queue" )
+	}
+}
+
+func TestEnqueueErrFull( t *testing.T ) {
+	var q closeTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 1 ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	if err := q.EnqueueErr( 1 ); err != nil {
+		t.Errorf( "Enqueue into empty queue failed: %s", err )
+	}
+	if err := q.EnqueueErr( 2 ); err != ErrFull {
+		t.Errorf( "Enqueue into full queue reports %v instead of ErrFull", err )
+	}
+}
+
+func TestTryEnqueueClosed( t *testing.T ) {
+	for _, config := range allConfigs( 8 ) {
+		var q closeTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		if !q.TryEnqueue( 1 ) {
+			t.Errorf( "TryEnqueue fails on open queue with flags %x", config.Flags )
+		}
+		q.Close()
+		if q.TryEnqueue( 2 ) {
+			t.Errorf( "TryEnqueue succeeds on closed queue with flags %x", config.Flags )
+		}
+		if x, ok := q.DequeueWait(); !ok || ( x != 1 ) {
+			t.Errorf( "DequeueWait returned %d, %v instead of 1 with flags %x", x, ok, config.Flags )
+		}
+		if x, ok := q.DequeueWait(); ok {
+			t.Errorf( "Element %d enqueued by failed TryEnqueue with flags %x", x, config.Flags )
+		}
+	}
+}
+
+func TestCloseParallel( t *testing.T ) {
+	const writers = 4
+	const readers = 4
+	for _, config := range filterConfigs( allConfigs( 8 ), concurrentConfig ) {
+		nreaders := readers
+		nwriters := writers
+		if ( config.Flags & FMultiReader ) == 0 {
+			nreaders = 1
+		}
+		if ( config.Flags & FMultiWriter ) == 0 {
+			nwriters = 1
+		}
+		var q closeTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		var enqueued, dequeued int64
+		var wg sync.WaitGroup
+		for w := 0; w < nwriters; w++ {
+			wg.Add( 1 )
+			go func() {
+				defer wg.Done()
+				for q.EnqueueErr( 1 ) == nil {
+					atomic.AddInt64( &enqueued, 1 )
+				}
+			}()
+		}
+		for r := 0; r < nreaders; r++ {
+			wg.Add( 1 )
+			go func() {
+				defer wg.Done()
+				for {
+					x, ok := q.DequeueWait()
+					if !ok {
+						return
+					}
+					atomic.AddInt64( &dequeued, int64( x ) )
+				}
+			}()
+		}
+		time.Sleep( 20 * time.Millisecond )
+		q.Close()
+		wg.Wait()
+		if enqueued != dequeued {
+			t.Errorf( "%d elements enqueued, but %d dequeued with flags %x", enqueued, dequeued, config.Flags )
+		}
+	}
+}
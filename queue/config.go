@@ -43,15 +43,70 @@ const(
 	// This flag is mutually exclusive with FNonConcurrent
 	FMultiWriter
 
+	// FLockFree indicates that
+	// the queue must be implemented without locks,
+	// so that a stalled goroutine cannot prevent others from making progress.
+	// Without this flag, lock-free implementations are still chosen
+	// for configurations where they are known to perform best.
+	FLockFree
+
+	// FBounded indicates that
+	// the queue must not hold more elements than a fixed limit.
+	// Use the Bounded method to set this flag along with the limit.
+	FBounded
+
+	// FSplitLock indicates that
+	// readers and writers must not contend for the same lock.
+	// Lock-free implementations trivially satisfy this requirement.
+	FSplitLock
+
+	// FRelaxedOrder indicates that
+	// the queue need not be strictly FIFO.
+	// Elements may then be dequeued in a different order than they were
+	// enqueued, in exchange for less contention among many writers.
+	FRelaxedOrder
+
 	// FNotImplemented forces the configurator to assume that there is no
 	// implementation for the specified configuration.
 	// Can be used for testing purposes.
 	FNotImplemented Flags = 1 << 63
 )
 
+// OverflowPolicy determines what happens when an element is enqueued
+// into a full bounded queue.
+type OverflowPolicy int
+
+// These are the available overflow policies.
+const(
+	// OverflowReject rejects the new element.
+	// The enqueueing function panics with ErrFull.
+	OverflowReject OverflowPolicy = iota
+
+	// OverflowBlock makes the enqueueing function wait until another
+	// goroutine has dequeued an element.
+	// Waiting writers are served in FIFO order (see GenericQueue.EnqueueWait).
+	// This policy is not valid for non-concurrent queues.
+	// Since waiting requires a lock,
+	// there is no lock-free or split-lock implementation with this policy.
+	OverflowBlock
+
+	// OverflowDropOldest discards the oldest element in the queue
+	// to make room for the new element.
+	// The queue thus becomes an overwriting ring buffer.
+	OverflowDropOldest
+
+	// OverflowDropNewest discards the new element.
+	OverflowDropNewest
+)
+
 // DefaultInitialCapacity is the initial capacity used by DefaultConfig()
 const DefaultInitialCapacity = 4
 
+// DefaultShrinkRatio is the shrink ratio used by DefaultConfig().
+// It is negative, so queues keep their storage for the next burst
+// unless Shrink is called.
+const DefaultShrinkRatio = -1.0
+
 // Config holds the configuration for a queue
 type Config struct {
 	// Flags denotes the configuration flags for Make.
@@ -59,12 +114,46 @@ type Config struct {
 
 	// initialCapacity denotes the initial capacity of the queue.
 	initialCapacity int
+
+	// limit denotes the maximum number of elements in a bounded queue.
+	limit int
+
+	// overflow denotes the overflow policy of a bounded queue.
+	overflow OverflowPolicy
+
+	// shrinkRatio denotes the maximum ratio of unused storage
+	// to the number of elements in the queue.
+	// It is only used if shrinkSet is true.
+	shrinkRatio float64
+
+	// shrinkSet indicates whether shrinkRatio has been set.
+	// Otherwise, DefaultShrinkRatio is used,
+	// so that a zero Config does not release storage
+	// after every burst.
+	shrinkSet bool
+
+	// implementation is the name of the implementation to use.
+	// If empty, the best matching implementation is used.
+	implementation string
+
+	// less is the ordering function of a priority queue,
+	// or nil for a FIFO queue.
+	less interface{}
 }
 
 // IsValid checks whether the configuration is valid.
 func ( c *Config ) IsValid() bool {
 	if ( ( c.Flags & FNonConcurrent ) != 0 ) && ( ( c.Flags & ( FMultiReader | FMultiWriter ) ) != 0 ) {
 		return false
+	} else if ( ( c.Flags & FBounded ) != 0 ) != ( c.limit > 0 ) {
+		return false
+	} else if ( c.overflow < OverflowReject ) || ( c.overflow > OverflowDropNewest ) {
+		return false
+	} else if ( ( c.Flags & FNonConcurrent ) != 0 ) && ( c.overflow == OverflowBlock ) {
+		return false
+	} else if c.shrinkRatio != c.shrinkRatio {
+		// NaN
+		return false
 	} else {
 		return true
 	}
@@ -113,6 +202,36 @@ func ( c *Config ) MultiWriter() *Config {
 	return c
 }
 
+// LockFree selects queue types which do not use locks.
+func ( c *Config ) LockFree() *Config {
+	c.Flags |= FLockFree
+
+	return c
+}
+
+// SplitLock selects queue types where dequeueing goroutines
+// do not block enqueueing goroutines and vice versa.
+func ( c *Config ) SplitLock() *Config {
+	c.Flags |= FSplitLock
+
+	return c
+}
+
+// RelaxedOrder selects queue types which may trade strict FIFO order
+// for less contention among writers.
+func ( c *Config ) RelaxedOrder() *Config {
+	c.Flags |= FRelaxedOrder
+
+	return c
+}
+
+// StrictOrder selects strictly FIFO queue types.
+func ( c *Config ) StrictOrder() *Config {
+	c.Flags &= ^FRelaxedOrder
+
+	return c
+}
+
 // InitialCapacity sets the initial capacity for the queue.
 // A negative value or a very small non-negative value will be increased
 // to the minimum capacity for the selected queue automatically.
@@ -122,23 +241,128 @@ func ( c *Config ) InitialCapacity( capacity int ) *Config {
 	return c
 }
 
+// Bounded limits the queue to hold at most limit elements.
+// What happens when an element is enqueued into a full bounded queue
+// is determined by the overflow policy, see Overflow.
+// The initial capacity is reduced to the limit if necessary.
+// A non-positive value for limit makes the queue unbounded again.
+func ( c *Config ) Bounded( limit int ) *Config {
+	if limit > 0 {
+		c.Flags |= FBounded
+		c.limit = limit
+	} else {
+		c.Flags &= ^FBounded
+		c.limit = 0
+	}
+
+	return c
+}
+
+// Overflow sets the overflow policy for bounded queues.
+// The default policy is OverflowReject.
+// The overflow policy has no effect on unbounded queues.
+func ( c *Config ) Overflow( policy OverflowPolicy ) *Config {
+	c.overflow = policy
+
+	return c
+}
+
+// Shrink sets the shrink ratio for the queue.
+// After a burst of traffic, a queue may hold on to storage
+// it no longer needs.
+// As the queue drains, storage is handed back to the garbage collector
+// as soon as the unused storage exceeds ratio times the number of
+// elements in the queue,
+// except for the initial capacity, which is always kept.
+// A ratio of 0 releases unused storage as early as possible.
+// A negative ratio means that storage is never released.
+// Queues which allocate their storage per element or all at once
+// are not affected by the shrink ratio.
+// If Shrink is never called, DefaultShrinkRatio is used,
+// so storage is never released.
+func ( c *Config ) Shrink( ratio float64 ) *Config {
+	c.shrinkRatio = ratio
+	c.shrinkSet = true
+
+	return c
+}
+
+// effectiveShrinkRatio returns the shrink ratio set with Shrink,
+// or DefaultShrinkRatio if none has been set.
+func ( c *Config ) effectiveShrinkRatio() float64 {
+	if !c.shrinkSet {
+		return DefaultShrinkRatio
+	}
+
+	return c.shrinkRatio
+}
+
+// Implementation selects the registered implementation with the given name
+// (see Register).
+// Make fails if there is no such implementation
+// or if it cannot handle the configuration.
+// The built-in implementations are "simple", "spsc", "sharded", "mpsc",
+// "bounded", "locked", "twolock", and "ms".
+// An empty name selects the best matching implementation,
+// which is the default.
+func ( c *Config ) Implementation( name string ) *Config {
+	c.implementation = name
+
+	return c
+}
+
+// Priority turns the queue into a priority queue ordered by less,
+// which must be a function of type func( a, b T ) bool,
+// where T is the element type of the queue structure.
+// Dequeue then returns the minimum element instead of the oldest,
+// and elements which compare equal are dequeued in FIFO order.
+// Alternatively, the queue structure can have a less function
+// (see GenericQueue.Less), which takes precedence.
+// Priority queues are protected by a single lock,
+// so they cannot be lock-free or split locks.
+// With OverflowDropOldest, a full priority queue discards
+// its minimum element.
+// A nil argument makes the queue FIFO again.
+func ( c *Config ) Priority( less interface{} ) *Config {
+	c.less = less
+
+	return c
+}
+
 // DefaultConfig returns a default configuration suitable for most uses.
 func DefaultConfig() *Config {
 	return &Config{
 		Flags: FMultiReader | FMultiWriter,
 		initialCapacity: DefaultInitialCapacity,
+		shrinkRatio: DefaultShrinkRatio,
+		shrinkSet: true,
+	}
+}
+
+// parameters returns the parameters for registered implementations.
+func ( c *Config ) parameters() Parameters {
+	return Parameters{
+		Flags: c.Flags,
+		InitialCapacity: c.initialCapacity,
+		Limit: c.limit,
+		Overflow: c.overflow,
+		ShrinkRatio: c.effectiveShrinkRatio(),
 	}
 }
 
 // factory returns a factory for this configuration.
 // If no matching implementation exists, nil is returned.
 func ( c *Config ) factory() factory {
+	return c.factoryFor( nil )
+}
+
+// factoryFor returns a factory for this configuration
+// which is accepted by the given function (see selectFactory).
+// If no such factory exists, nil is returned.
+func ( c *Config ) factoryFor( accept func( f factory ) bool ) factory {
 	if ( c.Flags & FNotImplemented ) != 0 {
 		return nil
 	}
-	if ( c.Flags & FNonConcurrent ) != 0 {
-		return newSimpleQueueFactory( c.initialCapacity )
-	} else {
-		return newLockedQueueFactory( c.initialCapacity )
-	}
+
+	return selectFactory( c, accept )
 }
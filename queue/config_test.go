@@ -26,6 +26,113 @@ import(
 	"testing"
 )
 
+// allConfigs returns configurations covering the built-in implementations.
+// Bounded configurations hold up to limit elements.
+// Tests pick the configurations they need with filterConfigs.
+func allConfigs( limit int ) []*Config {
+	return []*Config{
+		DefaultConfig().NonConcurrent(),
+		DefaultConfig().SingleReader().SingleWriter(),
+		DefaultConfig().SingleReader(),
+		DefaultConfig(),
+		DefaultConfig().LockFree(),
+		DefaultConfig().SplitLock(),
+		DefaultConfig().RelaxedOrder(),
+		DefaultConfig().NonConcurrent().Bounded( limit ),
+		DefaultConfig().Bounded( limit ),
+		DefaultConfig().Bounded( limit ).Implementation( "locked" ),
+		DefaultConfig().Bounded( limit ).Overflow( OverflowBlock ),
+	}
+}
+
+// configFilter selects configurations in filterConfigs.
+type configFilter func( c *Config ) bool
+
+// filterConfigs returns the configurations accepted by all filters.
+func filterConfigs( configs []*Config, filters ...configFilter ) []*Config {
+	var result []*Config
+outer:
+	for _, config := range configs {
+		for _, filter := range filters {
+			if !filter( config ) {
+				continue outer
+			}
+		}
+		result = append( result, config )
+	}
+
+	return result
+}
+
+// concurrentConfig accepts configurations for concurrent queues.
+func concurrentConfig( c *Config ) bool {
+	return ( c.Flags & FNonConcurrent ) == 0
+}
+
+// multiReaderConfig accepts configurations for queues with multiple readers.
+func multiReaderConfig( c *Config ) bool {
+	return ( c.Flags & FMultiReader ) != 0
+}
+
+// boundedConfig accepts configurations for bounded queues.
+func boundedConfig( c *Config ) bool {
+	return ( c.Flags & FBounded ) != 0
+}
+
+// unboundedConfig accepts configurations for unbounded queues.
+func unboundedConfig( c *Config ) bool {
+	return ( c.Flags & FBounded ) == 0
+}
+
+// strictOrderConfig accepts configurations for strict FIFO queues.
+func strictOrderConfig( c *Config ) bool {
+	return ( c.Flags & FRelaxedOrder ) == 0
+}
+
+// lockingConfig accepts configurations
+// which may be implemented with a single lock.
+func lockingConfig( c *Config ) bool {
+	return ( c.Flags & ( FLockFree | FSplitLock ) ) == 0
+}
+
+// sequencingConfig accepts configurations
+// which support sequence numbers.
+func sequencingConfig( c *Config ) bool {
+	return ( c.Flags & ( FLockFree | FRelaxedOrder ) ) == 0
+}
+
+// unpinnedConfig accepts configurations
+// which do not select an implementation by name.
+func unpinnedConfig( c *Config ) bool {
+	return c.implementation == ""
+}
+
+// nonBlockingConfig accepts configurations
+// without the OverflowBlock policy.
+func nonBlockingConfig( c *Config ) bool {
+	return c.overflow != OverflowBlock
+}
+
+// unfailingConfig accepts configurations for queues
+// which are unbounded or block on overflow,
+// so that enqueueing never fails.
+func unfailingConfig( c *Config ) bool {
+	return !boundedConfig( c ) || ( c.overflow == OverflowBlock )
+}
+
+// withOverflow sets the overflow policy of the given configurations.
+// Configurations which become invalid are dropped.
+func withOverflow( configs []*Config, policy OverflowPolicy ) []*Config {
+	var result []*Config
+	for _, config := range configs {
+		if config.Overflow( policy ).IsValid() {
+			result = append( result, config )
+		}
+	}
+
+	return result
+}
+
 func TestIsValid( t *testing.T ) {
 	config := DefaultConfig()
 	if !config.IsValid() {
@@ -59,6 +166,22 @@ func TestIsValid( t *testing.T ) {
 	if !config.IsValid() {
 		t.Error( "Multi reader/multi writer config is not valid" )
 	}
+	config.Flags = FMultiReader | FMultiWriter | FLockFree
+	if !config.IsValid() {
+		t.Error( "Lock-free multi reader/multi writer config is not valid" )
+	}
+	config.Flags = FMultiReader | FMultiWriter | FBounded
+	if config.IsValid() {
+		t.Error( "Bounded config without limit is valid" )
+	}
+	config.Flags = FMultiReader | FMultiWriter | FRelaxedOrder
+	if !config.IsValid() {
+		t.Error( "Relaxed order multi reader/multi writer config is not valid" )
+	}
+	config.Flags = FNonConcurrent | FRelaxedOrder
+	if !config.IsValid() {
+		t.Error( "Relaxed order non-concurrent config is not valid" )
+	}
 	config.Flags = FNotImplemented
 	if !config.IsValid() {
 		t.Error( "Not implemented config is not valid" )
@@ -167,6 +290,52 @@ func TestMultiWriter( t *testing.T ) {
 	}
 }
 
+func TestLockFree( t *testing.T ) {
+	config := DefaultConfig()
+	config.LockFree()
+	if ( config.Flags & FLockFree ) == 0 {
+		t.Error( "Lock-free flag not set" )
+	}
+	if ( config.Flags & ( FMultiReader | FMultiWriter ) ) != ( FMultiReader | FMultiWriter ) {
+		t.Error( "LockFree() changed concurrency flags" )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after LockFree()" )
+	}
+}
+
+func TestSplitLock( t *testing.T ) {
+	config := DefaultConfig()
+	config.SplitLock()
+	if ( config.Flags & FSplitLock ) == 0 {
+		t.Error( "Split lock flag not set" )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after SplitLock()" )
+	}
+}
+
+func TestRelaxedOrder( t *testing.T ) {
+	config := DefaultConfig()
+	if ( config.Flags & FRelaxedOrder ) != 0 {
+		t.Error( "Relaxed order flag set in default configuration" )
+	}
+	config.RelaxedOrder()
+	if ( config.Flags & FRelaxedOrder ) == 0 {
+		t.Error( "Relaxed order flag not set" )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after RelaxedOrder()" )
+	}
+	config.StrictOrder()
+	if ( config.Flags & FRelaxedOrder ) != 0 {
+		t.Error( "Relaxed order flag set after StrictOrder()" )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after StrictOrder()" )
+	}
+}
+
 func TestConfigCapacity( t *testing.T ) {
 	config := DefaultConfig()
 	config.InitialCapacity( 42 )
@@ -185,6 +354,109 @@ func TestConfigCapacity( t *testing.T ) {
 	}
 }
 
+func TestBounded( t *testing.T ) {
+	config := DefaultConfig()
+	config.Bounded( 42 )
+	if ( config.Flags & FBounded ) == 0 {
+		t.Error( "Bounded flag not set" )
+	}
+	if config.limit != 42 {
+		t.Errorf( "Bad limit: %d, expected 42", config.limit )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after Bounded()" )
+	}
+	config.Bounded( 0 )
+	if ( config.Flags & FBounded ) != 0 {
+		t.Error( "Bounded flag set after Bounded( 0 )" )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after Bounded( 0 )" )
+	}
+}
+
+func TestOverflow( t *testing.T ) {
+	config := DefaultConfig().Bounded( 10 )
+	if config.overflow != OverflowReject {
+		t.Errorf( "Default overflow policy %d is not OverflowReject", config.overflow )
+	}
+	for _, policy := range []OverflowPolicy{ OverflowReject, OverflowBlock, OverflowDropOldest, OverflowDropNewest } {
+		config.Overflow( policy )
+		if config.overflow != policy {
+			t.Errorf( "Bad overflow policy: %d, expected %d", config.overflow, policy )
+		}
+		if !config.IsValid() {
+			t.Errorf( "Configuration not valid after Overflow( %d )", policy )
+		}
+	}
+	config.Overflow( OverflowDropNewest + 1 )
+	if config.IsValid() {
+		t.Error( "Configuration with unknown overflow policy is valid" )
+	}
+	config.Overflow( OverflowBlock ).NonConcurrent()
+	if config.IsValid() {
+		t.Error( "Non-concurrent configuration with blocking overflow policy is valid" )
+	}
+}
+
+func TestShrink( t *testing.T ) {
+	config := DefaultConfig()
+	if config.shrinkRatio != DefaultShrinkRatio {
+		t.Errorf( "Default shrink ratio %g != %g", config.shrinkRatio, DefaultShrinkRatio )
+	}
+	config.Shrink( 0.25 )
+	if config.shrinkRatio != 0.25 {
+		t.Errorf( "Bad shrink ratio: %g, expected 0.25", config.shrinkRatio )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after Shrink()" )
+	}
+	zero := 0.0
+	config.Shrink( zero / zero )
+	if config.IsValid() {
+		t.Error( "Configuration with NaN shrink ratio is valid" )
+	}
+	// A zero configuration uses the default shrink ratio,
+	// but an explicit ratio of 0 is kept.
+	config = &Config{}
+	if config.effectiveShrinkRatio() != DefaultShrinkRatio {
+		t.Errorf( "Zero configuration has shrink ratio %g instead of %g", config.effectiveShrinkRatio(), DefaultShrinkRatio )
+	}
+	config.Shrink( 0 )
+	if config.effectiveShrinkRatio() != 0 {
+		t.Errorf( "Bad shrink ratio: %g, expected 0", config.effectiveShrinkRatio() )
+	}
+}
+
+func TestImplementation( t *testing.T ) {
+	config := DefaultConfig()
+	if config.implementation != "" {
+		t.Errorf( "Default configuration pins implementation '%s'", config.implementation )
+	}
+	config.Implementation( "twolock" )
+	if config.implementation != "twolock" {
+		t.Errorf( "Bad implementation: '%s', expected 'twolock'", config.implementation )
+	}
+	if !config.IsValid() {
+		t.Error( "Configuration not valid after Implementation()" )
+	}
+	if _, ok := config.factory().( *twoLockQueueFactory ); !ok {
+		t.Error( "Pinned implementation not selected" )
+	}
+	config.Implementation( "spsc" )
+	if config.factory() != nil {
+		t.Error( "Pinned implementation selected although it does not support multiple readers" )
+	}
+	config.Implementation( "nonexistent" )
+	if config.factory() != nil {
+		t.Error( "Unknown implementation selected" )
+	}
+	config.Implementation( "" )
+	if _, ok := config.factory().( *lockedQueueFactory ); !ok {
+		t.Error( "Default implementation not selected after unpinning" )
+	}
+}
+
 func TestFactory( t *testing.T ) {
 	config := DefaultConfig()
 	factory := config.factory()
@@ -197,3 +469,66 @@ func TestFactory( t *testing.T ) {
 		t.Error( "Not implemented configuration implemented" )
 	}
 }
+
+func TestFactorySelection( t *testing.T ) {
+	config := DefaultConfig().NonConcurrent()
+	if _, ok := config.factory().( *simpleQueueFactory ); !ok {
+		t.Error( "Non-concurrent configuration does not select simple queue" )
+	}
+	config = DefaultConfig().SingleReader().SingleWriter()
+	if _, ok := config.factory().( *spscQueueFactory ); !ok {
+		t.Error( "Single reader/single writer configuration does not select SPSC queue" )
+	}
+	config = DefaultConfig().MultiWriter().SingleReader()
+	if _, ok := config.factory().( *mpscQueueFactory ); !ok {
+		t.Error( "Single reader/multi writer configuration does not select MPSC queue" )
+	}
+	config = DefaultConfig()
+	if _, ok := config.factory().( *lockedQueueFactory ); !ok {
+		t.Error( "Default configuration does not select locked queue" )
+	}
+	config = DefaultConfig().LockFree()
+	if _, ok := config.factory().( *msQueueFactory ); !ok {
+		t.Error( "Lock-free default configuration does not select Michael-Scott queue" )
+	}
+	config = DefaultConfig().SingleWriter().LockFree()
+	if _, ok := config.factory().( *msQueueFactory ); !ok {
+		t.Error( "Lock-free multi reader/single writer configuration does not select Michael-Scott queue" )
+	}
+	config = DefaultConfig().SplitLock()
+	if _, ok := config.factory().( *twoLockQueueFactory ); !ok {
+		t.Error( "Split lock default configuration does not select two-lock queue" )
+	}
+	config = DefaultConfig().SplitLock().LockFree()
+	if _, ok := config.factory().( *msQueueFactory ); !ok {
+		t.Error( "Split lock lock-free configuration does not select Michael-Scott queue" )
+	}
+	config = DefaultConfig().RelaxedOrder()
+	if _, ok := config.factory().( *shardedQueueFactory ); !ok {
+		t.Error( "Relaxed order default configuration does not select sharded queue" )
+	}
+	config = DefaultConfig().SingleReader().RelaxedOrder()
+	if _, ok := config.factory().( *shardedQueueFactory ); !ok {
+		t.Error( "Relaxed order single reader/multi writer configuration does not select sharded queue" )
+	}
+	config = DefaultConfig().SingleWriter().RelaxedOrder()
+	if _, ok := config.factory().( *shardedQueueFactory ); !ok {
+		t.Error( "Relaxed order multi reader/single writer configuration does not select sharded queue" )
+	}
+	config = DefaultConfig().NonConcurrent().RelaxedOrder()
+	if _, ok := config.factory().( *simpleQueueFactory ); !ok {
+		t.Error( "Relaxed order non-concurrent configuration does not select simple queue" )
+	}
+	config = DefaultConfig().NonConcurrent().Bounded( 10 )
+	if f, ok := config.factory().( *simpleQueueFactory ); !ok || ( f.limit != 10 ) {
+		t.Error( "Bounded non-concurrent configuration does not select bounded simple queue" )
+	}
+	config = DefaultConfig().Bounded( 10 )
+	if _, ok := config.factory().( *boundedQueueFactory ); !ok {
+		t.Error( "Bounded default configuration does not select bounded queue" )
+	}
+	config = DefaultConfig().Bounded( 10 ).Implementation( "locked" )
+	if f, ok := config.factory().( *lockedQueueFactory ); !ok || ( f.limit != 10 ) {
+		t.Error( "Bounded configuration pinned to locked queue does not select bounded locked queue" )
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync/atomic"
+)
+
+// asDrainQueue finds the queue supporting drain and clear
+// among q and the queues wrapped by q.
+// If there is no such queue, nil is returned.
+func asDrainQueue( q interfaceQueue ) drainQueue {
+	for {
+		switch qq := q.( type ) {
+		case *overflowQueue:
+			// Blocked writers must be woken up after draining.
+			if asDrainQueue( qq.queue ) == nil {
+				return nil
+			}
+			return qq
+		case *flushQueue:
+			// Drained elements must be accounted for.
+			if asDrainQueue( qq.queue ) == nil {
+				return nil
+			}
+			return qq
+		case *sequenceQueue:
+			// Drained elements use up their sequence numbers.
+			if asDrainQueue( qq.queue ) == nil {
+				return nil
+			}
+			return qq
+		case drainQueue:
+			return qq
+		case *implementationQueue:
+			if drainer, ok := qq.impl.( Drainer ); ok {
+				return implementationDrainer{ drainer }
+			}
+			return nil
+		case wrapperQueue:
+			q = qq.unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// drainNodes removes all nodes after the dummy node head
+// of a linked list with a single reader.
+// Nodes linked while drainNodes is running are removed as well.
+// The new dummy node is returned as last,
+// along with the number n of removed nodes.
+// If keep is true, the values of the removed nodes are returned in xs.
+func drainNodes( head *listNode, keep bool ) ( last *listNode, xs []interface{}, n int ) {
+	last = head
+	for {
+		next := ( *listNode )( atomic.LoadPointer( &last.next ) )
+		if next == nil {
+			return
+		}
+		if keep {
+			xs = append( xs, next.value )
+		}
+		next.value = nil
+		last = next
+		n++
+	}
+}
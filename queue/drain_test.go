@@ -0,0 +1,183 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// drainTestQueue is used to test drain and clear.
+type drainTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Len func() int `queue:"len"`
+	Cap func() int `queue:"cap"`
+	Drain func() []int `queue:"drain"`
+	Clear func() `queue:"clear"`
+}
+
+func TestDrain( t *testing.T ) {
+	const n = 50
+	for _, config := range allConfigs( 100 ) {
+		var q drainTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		if xs := q.Drain(); len( xs ) != 0 {
+			t.Errorf( "Draining empty queue returned %v with flags %x", xs, config.Flags )
+		}
+		for i := 0; i < n; i++ {
+			q.Enqueue( i )
+		}
+		q.Dequeue()
+		q.Dequeue()
+		xs := q.Drain()
+		if len( xs ) != n - 2 {
+			t.Errorf( "Drain returned %d elements instead of %d with flags %x", len( xs ), n - 2, config.Flags )
+		}
+		for i, x := range xs {
+			if x != i + 2 {
+				t.Errorf( "Drain returned %d instead of %d with flags %x", x, i + 2, config.Flags )
+			}
+		}
+		if q.Len() != 0 {
+			t.Errorf( "Length %d after drain with flags %x", q.Len(), config.Flags )
+		}
+		if x, ok := q.Dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue after drain with flags %x: %d", config.Flags, x )
+		}
+		// The queue must remain usable and keep its order.
+		for i := 0; i < n; i++ {
+			q.Enqueue( i )
+		}
+		for i := 0; i < n; i++ {
+			if x, ok := q.Dequeue(); !ok || ( x != i ) {
+				t.Errorf( "Dequeue after drain returned %d, %v instead of %d with flags %x", x, ok, i, config.Flags )
+			}
+		}
+	}
+}
+
+func TestClear( t *testing.T ) {
+	const n = 10000
+	for _, config := range allConfigs( 100 ) {
+		var q drainTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		initialCap := q.Cap()
+		for i := 0; ( i < n ) && ( ( config.Flags & FBounded ) == 0 || i < config.limit ); i++ {
+			q.Enqueue( i )
+		}
+		q.Clear()
+		if q.Len() != 0 {
+			t.Errorf( "Length %d after clear with flags %x", q.Len(), config.Flags )
+		}
+		// The lock-free single reader single writer queue cannot release
+		// the segment the writer is filling, so it may keep one segment.
+		maxCap := initialCap
+		if ( config.Flags & ( FMultiReader | FMultiWriter ) ) == 0 && ( config.Flags & FNonConcurrent ) == 0 && maxCap < maxSegmentSize {
+			maxCap = maxSegmentSize
+		}
+		if q.Cap() > maxCap {
+			t.Errorf( "Capacity %d after clear exceeds initial capacity %d with flags %x", q.Cap(), initialCap, config.Flags )
+		}
+		if x, ok := q.Dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue after clear with flags %x: %d", config.Flags, x )
+		}
+		q.Enqueue( 42 )
+		if x, ok := q.Dequeue(); !ok || ( x != 42 ) {
+			t.Errorf( "Dequeue after clear returned %d, %v with flags %x", x, ok, config.Flags )
+		}
+	}
+}
+
+func TestClearWakesBlockedWriter( t *testing.T ) {
+	var q drainTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 1 ).Overflow( OverflowBlock ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.Enqueue( 1 )
+	done := make( chan struct{} )
+	go func() {
+		q.Enqueue( 2 )
+		close( done )
+	}()
+	time.Sleep( 10 * time.Millisecond )
+	q.Clear()
+	select {
+	case <-done:
+	case <-time.After( 5 * time.Second ):
+		t.Fatal( "Blocked writer not woken up by clear" )
+	}
+	if xs := q.Drain(); ( len( xs ) != 1 ) || ( xs[0] != 2 ) {
+		t.Errorf( "Drain returned %v instead of [2]", xs )
+	}
+}
+
+func TestDrainParallel( t *testing.T ) {
+	const writers = 4
+	const iterations = 5000
+	for _, config := range filterConfigs( allConfigs( 100 ), concurrentConfig, unboundedConfig ) {
+		var q drainTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		w := writers
+		if ( config.Flags & FMultiWriter ) == 0 {
+			w = 1
+		}
+		total := int32( w * iterations )
+		seen := make( []int32, total )
+		var received int32
+		var wg sync.WaitGroup
+		wg.Add( w + 1 )
+		for id := 0; id < w; id++ {
+			go func( id int ) {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					q.Enqueue( id * iterations + i )
+				}
+			}( id )
+		}
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32( &received ) < total {
+				xs := q.Drain()
+				for _, x := range xs {
+					atomic.AddInt32( &seen[x], 1 )
+				}
+				atomic.AddInt32( &received, int32( len( xs ) ) )
+			}
+		}()
+		wg.Wait()
+		for x := range seen {
+			if seen[x] != 1 {
+				t.Errorf( "Element %d drained %d times with flags %x", x, seen[x], config.Flags )
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"errors"
+)
+
+// ErrFull is the error a bounded queue reports
+// when an element is enqueued while the queue is at its capacity limit.
+var ErrFull = errors.New( "Queue is full" )
+
+// ErrEmpty is the error reported
+// when a dequeue operation which does not wait finds the queue empty.
+// In particular, non-concurrent queues cannot wait,
+// since no other goroutine may enqueue an element in the meantime.
+var ErrEmpty = errors.New( "Queue is empty" )
+
+// ErrPending is the error reported
+// when waiting for elements to be consumed is not possible
+// because a non-concurrent queue still holds such elements.
+var ErrPending = errors.New( "Queue elements are pending" )
+
+// ErrDone is the error a queue panics with
+// when more elements are marked done than have been dequeued
+// (see GenericQueue.Done).
+var ErrDone = errors.New( "More queue elements marked done than dequeued" )
+
+// ErrClosed is the error reported
+// when an element is enqueued into a closed queue,
+// or when an element is dequeued from a closed queue
+// which has no elements left.
+var ErrClosed = errors.New( "Queue is closed" )
@@ -23,7 +23,10 @@ SOFTWARE.
 package queue
 
 import(
+	"context"
 	"reflect"
+	"sync/atomic"
+	"time"
 )
 
 // factory is an internal interface
@@ -41,56 +44,632 @@ type factory interface {
 	// makeDequeue creates the dequeueing method
 	makeDequeue( methodType reflect.Type ) reflect.Value
 
+	// queue returns the queue being prepared.
+	// Make uses it to create methods beyond enqueueing and dequeueing.
+	queue() interfaceQueue
+
 	// reset resets preparations without committing them.
 	// Calling reset before prepare() or after commit() has no effect.
 	reset()
 }
 
-// dbFactory is the basic building block for the factories of double-buffered queues.
-type dbFactory struct {
-	// capacityPerBuffer is the initial capacity of each of the queue buffers.
+// maxSegmentSize is the number of elements per segment in segmented queues.
+// Smaller segments are only used for queues with a lower capacity limit,
+// and for the first segment of queues with a lower initial capacity.
+// Simple queues grow their segments beyond this size
+// (see simpleQueue.newSegment).
+const maxSegmentSize = 128
+
+// maxGrownSegmentSize is the maximum number of elements per segment
+// in queues whose segments grow with the queue.
+// It bounds the latency of a single allocation.
+const maxGrownSegmentSize = 16384
+
+// segFactory is the basic building block for the factories of segmented queues.
+type segFactory struct {
+	// segmentSize is the number of elements per segment.
 	// It must be at least 1.
-	capacityPerBuffer int
+	segmentSize int
+
+	// initialCapacity is the number of elements allocated up front.
+	// It must be at least 1.
+	initialCapacity int
+
+	// shrinkRatio is the shrink ratio of the queue (see Config.Shrink).
+	shrinkRatio float64
 }
 
-// newDbFactory creates a new dbFactory for double-buffered queues.
+// newSegFactory creates a new segFactory for segmented queues.
 // The argument initialCapacity is the total initial capacity of the queue.
+// The argument limit is the capacity limit of the queue,
+// or 0 if the queue is unbounded.
+// The initial capacity never exceeds a positive limit.
 // Values too small will be corrected.
-func newDbFactory( initialCapacity int ) *dbFactory {
-	capacityPerBuffer := initialCapacity / 2
-	if capacityPerBuffer < 1 {
-		capacityPerBuffer = 1
+// The argument shrinkRatio is passed on to the queue.
+func newSegFactory( initialCapacity, limit int, shrinkRatio float64 ) *segFactory {
+	segmentSize := maxSegmentSize
+	if ( limit > 0 ) && ( limit < segmentSize ) {
+		segmentSize = limit
+	}
+	if ( limit > 0 ) && ( initialCapacity > limit ) {
+		initialCapacity = limit
+	}
+	if initialCapacity < 1 {
+		initialCapacity = 1
+	}
+
+	return &segFactory{
+		segmentSize: segmentSize,
+		initialCapacity: initialCapacity,
+		shrinkRatio: shrinkRatio,
+	}
+}
+
+// elementValue returns x as a value of the given element type.
+// If ok is false, or x is nil, the zero value is returned.
+// Sequenced elements are unwrapped (see sequenced).
+func elementValue( x interface{}, ok bool, elementType reflect.Type ) reflect.Value {
+	if sx, isSequenced := x.( *sequenced ); isSequenced {
+		x = sx.value
+	}
+	if !ok || ( x == nil ) {
+		return reflect.Zero( elementType )
+	}
+
+	return reflect.ValueOf( x )
+}
+
+// errorValue returns err as a value of the given error type.
+func errorValue( err error, errorType reflect.Type ) reflect.Value {
+	if err == nil {
+		return reflect.Zero( errorType )
+	}
+
+	return reflect.ValueOf( &err ).Elem()
+}
+
+// enqueueErr enqueues x into q.
+// If the queue panics with ErrFull or ErrClosed,
+// the panic is turned into an error.
+func enqueueErr( q interfaceQueue, x interface{} ) ( err error ) {
+	defer func() {
+		if r := recover(); r != nil {
+			if ( r != ErrFull ) && ( r != ErrClosed ) {
+				panic( r )
+			}
+			err = r.( error )
+		}
+	}()
+	q.enqueue( x )
+
+	return nil
+}
+
+// tryEnqueue enqueues x into q unless q is full or closed.
+// It never blocks.
+// The return value indicates whether x has been enqueued.
+func tryEnqueue( q interfaceQueue, x interface{} ) bool {
+	if bq, ok := q.( boundedInterfaceQueue ); ok {
+		return bq.tryEnqueue( x )
 	}
+	return enqueueErr( q, x ) == nil
+}
 
-	return &dbFactory{
-		capacityPerBuffer: capacityPerBuffer,
+// enqueueCtx enqueues x into q, waiting while q is full until ctx is done.
+// Queues which are not waitEnqueueQueues are never full.
+func enqueueCtx( ctx context.Context, q interfaceQueue, x interface{} ) error {
+	if wq, ok := q.( waitEnqueueQueue ); ok {
+		return wq.enqueueCtx( ctx, x )
 	}
+	return enqueueErr( q, x )
+}
+
+// closedChannel is a closed channel, signalling readiness right away.
+var closedChannel = make( chan struct{} )
+
+// doneContext is a context which is always done.
+var doneContext context.Context
+
+func init() {
+	var cancel context.CancelFunc
+	doneContext, cancel = context.WithCancel( context.Background() )
+	cancel()
+	close( closedChannel )
+}
+
+// waitingEnqueue returns a function enqueueing an element into q,
+// waiting while q is full (see overflowQueue.enqueueCtx).
+// Non-concurrent queues cannot wait,
+// so for them, the function fails with ErrFull instead.
+func waitingEnqueue( q interfaceQueue, concurrent bool ) func( ctx context.Context, x interface{} ) error {
+	if concurrent {
+		return func( ctx context.Context, x interface{} ) error {
+			return enqueueCtx( ctx, q, x )
+		}
+	}
+	return func( ctx context.Context, x interface{} ) error {
+		if err := enqueueCtx( doneContext, q, x ); err != context.Canceled {
+			return err
+		}
+		return ErrFull
+	}
+}
+
+// makeEnqueueWait creates the function interfacing the typed enqueueWait
+// function with the generic implementation of the queue.
+// Instead of returning an error, the function panics.
+func makeEnqueueWait( q interfaceQueue, concurrent bool, methodType reflect.Type ) reflect.Value {
+	enqueue := waitingEnqueue( q, concurrent )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		if err := enqueue( context.Background(), args[0].Interface() ); err != nil {
+			panic( err )
+		}
+		return []reflect.Value{}
+	} )
+}
+
+// makeEnqueueCtx creates the function interfacing the typed enqueueCtx
+// function with the generic implementation of the queue.
+func makeEnqueueCtx( q interfaceQueue, concurrent bool, methodType reflect.Type ) reflect.Value {
+	enqueue := waitingEnqueue( q, concurrent )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		err := enqueue( args[0].Interface().( context.Context ), args[1].Interface() )
+		return []reflect.Value{
+			errorValue( err, methodType.Out( 0 ) ),
+		}
+	} )
 }
 
 // makeEnqueue creates the function interfacing the typed enqueue function
 // with the generic implementation of the queue.
+// If the function returns an error,
+// ErrFull and ErrClosed are returned instead of panicking.
 func makeEnqueue( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	if methodType.NumOut() == 0 {
+		return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+			q.enqueue( args[0].Interface() )
+			return []reflect.Value{}
+		} )
+	}
 	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
-		q.enqueue( args[0].Interface() )
-		return []reflect.Value{}
+		err := enqueueErr( q, args[0].Interface() )
+		return []reflect.Value{
+			errorValue( err, methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeTryEnqueue creates the function interfacing the typed tryEnqueue
+// function with the generic implementation of the queue.
+func makeTryEnqueue( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		ok := tryEnqueue( q, args[0].Interface() )
+		return []reflect.Value{
+			reflect.ValueOf( ok ).Convert( methodType.Out( 0 ) ),
+		}
 	} )
 }
 
 // makeDequeue creates the function interfacing the typed dequeue function
 // with the generic implementation of the queue.
+// If the second return value of the function is an error,
+// it is ErrEmpty or ErrClosed if no element could be dequeued
+// (see tryDequeue).
 func makeDequeue( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	if methodType.Out( 1 ).Kind() == reflect.Bool {
+		return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+			x, ok := q.dequeue()
+			return []reflect.Value{
+				elementValue( x, ok, methodType.Out( 0 ) ),
+				reflect.ValueOf( ok ),
+			}
+		} )
+	}
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		x, err := tryDequeue( q )
+		return []reflect.Value{
+			elementValue( x, err == nil, methodType.Out( 0 ) ),
+			errorValue( err, methodType.Out( 1 ) ),
+		}
+	} )
+}
+
+// makeEnqueueBatch creates the function interfacing the typed batch enqueue
+// function with the generic implementation of the queue.
+func makeEnqueueBatch( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		xs := make( []interface{}, args[0].Len() )
+		for i := range xs {
+			xs[i] = args[0].Index( i ).Interface()
+		}
+		enqueueBatch( q, xs )
+		return []reflect.Value{}
+	} )
+}
+
+// makeDequeueBatch creates the function interfacing the typed batch dequeue
+// function with the generic implementation of the queue.
+func makeDequeueBatch( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	elementType := methodType.In( 0 ).Elem()
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		dst := args[0]
+		xs := make( []interface{}, dst.Len() )
+		n := dequeueBatch( q, xs )
+		for i := 0; i < n; i++ {
+			dst.Index( i ).Set( elementValue( xs[i], true, elementType ) )
+		}
+		return []reflect.Value{
+			reflect.ValueOf( n ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeDiscarded creates the function reporting the number of elements
+// discarded due to the overflow policy of the queue.
+// Queues without an overflow policy never discard elements.
+func makeDiscarded( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	oq, ok := q.( *overflowQueue )
 	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
-		x, ok := q.dequeue()
+		var discarded uint64
 		if ok {
+			discarded = oq.discardedCount()
+		}
+		return []reflect.Value{
+			reflect.ValueOf( discarded ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// waitingDequeue returns a function dequeueing an element from q.
+// If q is a waitQueue, the function waits as described for
+// waitQueue.dequeueCtx.
+// Otherwise, the queue is non-concurrent,
+// and the function does not wait (see tryDequeue).
+func waitingDequeue( q interfaceQueue ) func( ctx context.Context ) ( interface{}, error ) {
+	if wq, ok := q.( *waitQueue ); ok {
+		return wq.dequeueCtx
+	}
+	return func( ctx context.Context ) ( interface{}, error ) {
+		return tryDequeue( q )
+	}
+}
+
+// makeDequeueWait creates the function interfacing the typed blocking dequeue
+// function with the generic implementation of the queue.
+// If the function returns only an element, it panics instead of failing.
+// Otherwise, it only fails once the queue has been closed and drained.
+// Either way, it panics with ErrEmpty if it would have to wait on
+// a non-concurrent queue.
+func makeDequeueWait( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	dequeue := waitingDequeue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		x, err := dequeue( context.Background() )
+		if ( err != nil ) && ( ( err != ErrClosed ) || ( methodType.NumOut() == 1 ) ) {
+			panic( err )
+		}
+		if methodType.NumOut() == 1 {
 			return []reflect.Value{
-				reflect.ValueOf( x ),
-				reflect.ValueOf( ok ),
+				elementValue( x, true, methodType.Out( 0 ) ),
 			}
+		}
+		return []reflect.Value{
+			elementValue( x, err == nil, methodType.Out( 0 ) ),
+			reflect.ValueOf( err == nil ),
+		}
+	} )
+}
+
+// makeDequeueCtx creates the function interfacing the typed context-aware
+// dequeue function with the generic implementation of the queue.
+func makeDequeueCtx( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	dequeue := waitingDequeue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		x, err := dequeue( args[0].Interface().( context.Context ) )
+		return []reflect.Value{
+			elementValue( x, err == nil, methodType.Out( 0 ) ),
+			errorValue( err, methodType.Out( 1 ) ),
+		}
+	} )
+}
+
+// makeDequeueTimeout creates the function interfacing the typed timed dequeue
+// function with the generic implementation of the queue.
+// If q is not a waitQueue, the queue is non-concurrent,
+// and the function does not wait.
+func makeDequeueTimeout( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	wq, isWaitQueue := q.( *waitQueue )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		var x interface{}
+		var err error
+		if isWaitQueue {
+			x, err = wq.dequeueTimeout( time.Duration( args[0].Int() ) )
 		} else {
-			return []reflect.Value{
-				reflect.Zero( methodType.Out( 0 ) ),
-				reflect.ValueOf( ok ),
+			x, err = tryDequeue( q )
+		}
+		return []reflect.Value{
+			elementValue( x, err == nil, methodType.Out( 0 ) ),
+			reflect.ValueOf( err == nil ),
+		}
+	} )
+}
+
+// makeClose creates the function closing the queue.
+func makeClose( q *closeQueue, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		q.close()
+		return []reflect.Value{}
+	} )
+}
+
+// asPeekQueue finds the queue supporting peek
+// among q and the queues wrapped by q.
+// If there is no such queue, nil is returned.
+func asPeekQueue( q interfaceQueue ) peekQueue {
+	for {
+		switch qq := q.( type ) {
+		case peekQueue:
+			return qq
+		case *implementationQueue:
+			if peeker, ok := qq.impl.( Peeker ); ok {
+				return implementationPeeker{ peeker }
+			}
+			return nil
+		case wrapperQueue:
+			q = qq.unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// makePeek creates the function interfacing the typed peek function
+// with the generic implementation of the queue.
+// The queue must support peek (see asPeekQueue).
+func makePeek( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	pq := asPeekQueue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		x, ok := pq.peek()
+		return []reflect.Value{
+			elementValue( x, ok, methodType.Out( 0 ) ),
+			reflect.ValueOf( ok ),
+		}
+	} )
+}
+
+// asSizeQueue finds the queue supporting size reports
+// among q and the queues wrapped by q.
+// If there is no such queue, nil is returned.
+func asSizeQueue( q interfaceQueue ) sizeQueue {
+	for {
+		switch qq := q.( type ) {
+		case sizeQueue:
+			return qq
+		case *implementationQueue:
+			if sizer, ok := qq.impl.( Sizer ); ok {
+				return implementationSizer{ sizer }
+			}
+			return nil
+		case wrapperQueue:
+			q = qq.unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// makeLen creates the function reporting the number of elements
+// in the queue.
+// The queue must support size reports (see asSizeQueue).
+func makeLen( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	sq := asSizeQueue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		return []reflect.Value{
+			reflect.ValueOf( sq.count() ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeCap creates the function reporting the capacity of the queue.
+// For bounded queues, this is the capacity limit.
+// The queue must support size reports (see asSizeQueue).
+func makeCap( q interfaceQueue, limit int, methodType reflect.Type ) reflect.Value {
+	sq := asSizeQueue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		capacity := limit
+		if limit <= 0 {
+			capacity = sq.capacity()
+		}
+		return []reflect.Value{
+			reflect.ValueOf( capacity ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeIsEmpty creates the function reporting whether the queue is empty.
+// The queue must support size reports (see asSizeQueue).
+func makeIsEmpty( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	sq := asSizeQueue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		return []reflect.Value{
+			reflect.ValueOf( sq.count() == 0 ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeIsFull creates the function reporting whether the queue is full.
+// Unbounded queues, i. e., those with a limit of 0, are never full.
+// The queue must support size reports (see asSizeQueue).
+func makeIsFull( q interfaceQueue, limit int, methodType reflect.Type ) reflect.Value {
+	sq := asSizeQueue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		full := ( limit > 0 ) && ( sq.count() >= limit )
+		return []reflect.Value{
+			reflect.ValueOf( full ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeDrain creates the function interfacing the typed drain function
+// with the generic implementation of the queue.
+// The queue must support drain (see asDrainQueue).
+func makeDrain( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	dq := asDrainQueue( q )
+	elementType := methodType.Out( 0 ).Elem()
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		xs := dq.drain()
+		result := reflect.MakeSlice( methodType.Out( 0 ), len( xs ), len( xs ) )
+		for i, x := range xs {
+			result.Index( i ).Set( elementValue( x, true, elementType ) )
+		}
+		return []reflect.Value{ result }
+	} )
+}
+
+// makeClear creates the function clearing the queue.
+// The queue must support clear (see asDrainQueue).
+func makeClear( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	dq := asDrainQueue( q )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		dq.clear()
+		return []reflect.Value{}
+	} )
+}
+
+// makeAll creates the function iterating over a snapshot of the queue.
+// The queue must support snapshots (see asSnapshotQueue).
+func makeAll( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	sq := asSnapshotQueue( q )
+	elementType := methodType.In( 0 ).In( 0 )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		yield := args[0]
+		for _, x := range sq.snapshot() {
+			if !yield.Call( []reflect.Value{ elementValue( x, true, elementType ) } )[0].Bool() {
+				break
+			}
+		}
+		return []reflect.Value{}
+	} )
+}
+
+// makeConsume creates the function iterating over the elements of the queue
+// while dequeueing them.
+// The iteration stops when the queue is empty.
+func makeConsume( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	elementType := methodType.In( 0 ).In( 0 )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		yield := args[0]
+		for {
+			x, ok := q.dequeue()
+			if !ok {
+				break
 			}
+			if !yield.Call( []reflect.Value{ elementValue( x, true, elementType ) } )[0].Bool() {
+				break
+			}
+		}
+		return []reflect.Value{}
+	} )
+}
+
+// makeWaitEmpty creates the function waiting until the elements enqueued
+// so far have been consumed (see flushQueue.waitEmpty).
+// Non-concurrent queues cannot wait,
+// so for them, the function fails with ErrPending instead.
+func makeWaitEmpty( fq *flushQueue, concurrent bool, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		var err error
+		if concurrent {
+			err = fq.waitEmpty( args[0].Interface().( context.Context ) )
+		} else if !fq.isFlushed( atomic.LoadUint64( &fq.enqueued ) ) {
+			err = ErrPending
+		}
+		return []reflect.Value{
+			errorValue( err, methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeDone creates the function marking a dequeued element as done.
+func makeDone( fq *flushQueue, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		fq.done()
+		return []reflect.Value{}
+	} )
+}
+
+// isClosed reports whether done has been closed.
+// A nil channel is never closed.
+func isClosed( done <-chan struct{} ) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// makeReady creates the function returning a channel
+// which is closed once the queue is not empty or has been closed.
+// The queue must support size reports (see asSizeQueue).
+// If q is not a waitQueue, the queue is non-concurrent,
+// and the channel is always closed,
+// since no other goroutine may enqueue an element,
+// so waiting would never end.
+func makeReady( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	sq := asSizeQueue( q )
+	wq, waiting := q.( *waitQueue )
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		var ch <-chan struct{} = closedChannel
+		if waiting {
+			ch = wq.notEmpty.prepare()
+			if isClosed( wq.done ) || ( sq.count() != 0 ) {
+				ch = closedChannel
+			}
+		}
+		return []reflect.Value{
+			reflect.ValueOf( ch ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// findOverflowQueue returns the overflowQueue q is or wraps,
+// or nil if there is none.
+func findOverflowQueue( q interfaceQueue ) *overflowQueue {
+	for {
+		if oq, ok := q.( *overflowQueue ); ok {
+			return oq
+		}
+		wq, ok := q.( wrapperQueue )
+		if !ok {
+			return nil
+		}
+		q = wq.unwrap()
+	}
+}
+
+// makeNotFull creates the function returning a channel
+// which is closed once the queue is not full or has been closed.
+// Queues without an overflowQueue are never full.
+// For non-concurrent queues, the channel is always closed,
+// since no other goroutine may dequeue an element,
+// so waiting would never end.
+// The queue must support size reports (see asSizeQueue).
+func makeNotFull( q interfaceQueue, limit int, concurrent bool, methodType reflect.Type ) reflect.Value {
+	sq := asSizeQueue( q )
+	var oq *overflowQueue = nil
+	if concurrent {
+		oq = findOverflowQueue( q )
+	}
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		var ch <-chan struct{} = closedChannel
+		if oq != nil {
+			ch = oq.writable.prepare()
+			if isClosed( oq.done ) || ( sq.count() < limit ) {
+				ch = closedChannel
+			}
+		}
+		return []reflect.Value{
+			reflect.ValueOf( ch ).Convert( methodType.Out( 0 ) ),
 		}
 	} )
 }
@@ -0,0 +1,244 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"sync/atomic"
+)
+
+// flushQueue wraps a queue to keep track of consumed elements,
+// so that goroutines can wait until the elements enqueued
+// before a point in time have been consumed.
+// An element counts as consumed when it has been dequeued,
+// or, if acknowledge is true, when it has been marked done.
+// Elements discarded due to the overflow policy are consumed right away.
+// For bounded queues, the flushQueue sits between the overflowQueue
+// and the bounded queue, so that it sees discarded elements.
+type flushQueue struct {
+	queue interfaceQueue
+	acknowledge bool
+	// enqueued counts the enqueued elements,
+	// including those whose enqueue operation is still in progress.
+	// It is incremented before an element is enqueued,
+	// and decremented again if enqueueing fails.
+	// It must only be accessed atomically.
+	enqueued uint64
+	// dequeued counts the dequeued elements.
+	// It must only be accessed atomically.
+	dequeued uint64
+	// acknowledged counts the elements marked done.
+	// It must only be accessed atomically.
+	acknowledged uint64
+	// consumed counts the consumed elements.
+	// It must only be accessed atomically.
+	consumed uint64
+	// flushed is notified whenever elements have been consumed.
+	flushed signal
+}
+
+func newFlushQueue( queue interfaceQueue, acknowledge bool ) *flushQueue {
+	return &flushQueue{
+		queue: queue,
+		acknowledge: acknowledge,
+	}
+}
+
+func ( q *flushQueue ) enqueue( x interface{} ) {
+	atomic.AddUint64( &q.enqueued, 1 )
+	ok := false
+	defer func() {
+		if !ok {
+			q.retract( 1 )
+		}
+	}()
+	q.queue.enqueue( x )
+	ok = true
+}
+
+func ( q *flushQueue ) tryEnqueue( x interface{} ) bool {
+	atomic.AddUint64( &q.enqueued, 1 )
+	if !tryEnqueue( q.queue, x ) {
+		q.retract( 1 )
+		return false
+	}
+
+	return true
+}
+
+// enqueueBatch works like enqueue.
+// The wrapped queue does not fail partway through a batch,
+// as an overflowQueue uses tryEnqueueBatch instead.
+func ( q *flushQueue ) enqueueBatch( xs []interface{} ) {
+	atomic.AddUint64( &q.enqueued, uint64( len( xs ) ) )
+	ok := false
+	defer func() {
+		if !ok {
+			q.retract( len( xs ) )
+		}
+	}()
+	enqueueBatch( q.queue, xs )
+	ok = true
+}
+
+// tryEnqueueBatch works like tryEnqueue.
+func ( q *flushQueue ) tryEnqueueBatch( xs []interface{} ) int {
+	atomic.AddUint64( &q.enqueued, uint64( len( xs ) ) )
+	n := tryEnqueueBatch( q.queue, xs )
+	if n != len( xs ) {
+		q.retract( len( xs ) - n )
+	}
+
+	return n
+}
+
+// retract takes back the count of n elements which could not be enqueued.
+func ( q *flushQueue ) retract( n int ) {
+	atomic.AddUint64( &q.enqueued, ^uint64( n - 1 ) )
+	q.flushed.notify()
+}
+
+func ( q *flushQueue ) dequeue() ( x interface{}, ok bool ) {
+	x, ok = q.queue.dequeue()
+	if ok {
+		q.take( 1 )
+	}
+
+	return
+}
+
+func ( q *flushQueue ) dequeueBatch( dst []interface{} ) int {
+	n := dequeueBatch( q.queue, dst )
+	if n != 0 {
+		q.take( n )
+	}
+
+	return n
+}
+
+// take accounts for n dequeued elements.
+func ( q *flushQueue ) take( n int ) {
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+	if !q.acknowledge {
+		q.discard( n )
+	}
+}
+
+// discard accounts for n consumed elements.
+func ( q *flushQueue ) discard( n int ) {
+	atomic.AddUint64( &q.consumed, uint64( n ) )
+	q.flushed.notify()
+}
+
+// drop dequeues an element on behalf of the overflow policy.
+// The element is consumed without having to be marked done.
+func ( q *flushQueue ) drop() bool {
+	_, ok := q.queue.dequeue()
+	if ok {
+		q.discard( 1 )
+	}
+
+	return ok
+}
+
+// drain drains the wrapped queue, which must support it
+// (see asDrainQueue).
+func ( q *flushQueue ) drain() []interface{} {
+	xs := asDrainQueue( q.queue ).drain()
+	if len( xs ) != 0 {
+		q.take( len( xs ) )
+	}
+
+	return xs
+}
+
+// clear clears the wrapped queue, which must support it
+// (see asDrainQueue).
+// The removed elements are consumed right away.
+// The wrapped queue counts them as it removes them,
+// so elements enqueued concurrently are either removed and counted,
+// or kept.
+func ( q *flushQueue ) clear() int {
+	n := asDrainQueue( q.queue ).clear()
+	if n != 0 {
+		q.discard( n )
+	}
+
+	return n
+}
+
+func ( q *flushQueue ) unwrap() interfaceQueue {
+	return q.queue
+}
+
+// done marks a dequeued element as done.
+// It panics with ErrDone if more elements are marked done
+// than have been dequeued.
+// The count is only incremented once it is known to stay
+// within the dequeued elements,
+// so concurrent calls cannot make each other panic spuriously.
+func ( q *flushQueue ) done() {
+	for {
+		acknowledged := atomic.LoadUint64( &q.acknowledged )
+		if acknowledged >= atomic.LoadUint64( &q.dequeued ) {
+			panic( ErrDone )
+		}
+		if atomic.CompareAndSwapUint64( &q.acknowledged, acknowledged, acknowledged + 1 ) {
+			break
+		}
+	}
+	q.discard( 1 )
+}
+
+// isFlushed reports whether as many elements have been consumed
+// as given by target,
+// or whether all elements enqueued so far have been consumed.
+func ( q *flushQueue ) isFlushed( target uint64 ) bool {
+	consumed := atomic.LoadUint64( &q.consumed )
+
+	return ( consumed >= target ) || ( consumed >= atomic.LoadUint64( &q.enqueued ) )
+}
+
+// waitEmpty waits until the elements enqueued before the call
+// have been consumed, or until ctx is done,
+// in which case ctx.Err() is returned.
+// Since enqueued is incremented before an element becomes visible,
+// in a FIFO queue, consumed can only catch up with its value
+// once all elements enqueued before have been dequeued.
+func ( q *flushQueue ) waitEmpty( ctx context.Context ) error {
+	target := atomic.LoadUint64( &q.enqueued )
+	for {
+		if q.isFlushed( target ) {
+			return nil
+		}
+		ch := q.flushed.prepare()
+		if q.isFlushed( target ) {
+			return nil
+		}
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,280 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flushTestQueue is used to test waiting for consumption.
+type flushTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Drain func() []int `queue:"drain"`
+	WaitEmpty func( context.Context ) error `queue:"waitEmpty"`
+}
+
+// ackTestQueue is used to test waiting for elements marked done.
+type ackTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	WaitEmpty func( context.Context ) error `queue:"waitEmpty"`
+	Done func() `queue:"done"`
+}
+
+// waitEmptyAsync calls waitEmpty in a new goroutine
+// and returns once it is waiting.
+// The returned channel receives the result.
+func waitEmptyAsync( t *testing.T, waitEmpty func( context.Context ) error ) <-chan error {
+	result := make( chan error, 1 )
+	startWaiting( t, func() {
+		result <- waitEmpty( context.Background() )
+	} )
+
+	return result
+}
+
+// returnsSoon reports whether result receives a value within a short time.
+// It is meant for checking that a call does not return,
+// which does not fail spuriously on a slow machine.
+func returnsSoon( result <-chan error ) bool {
+	select {
+	case <-result:
+		return true
+	case <-time.After( 20 * time.Millisecond ):
+		return false
+	}
+}
+
+// returns reports whether result receives a value,
+// allowing plenty of time for slow machines.
+func returns( result <-chan error ) bool {
+	select {
+	case <-result:
+		return true
+	case <-time.After( 5 * time.Second ):
+		return false
+	}
+}
+
+func TestWaitEmpty( t *testing.T ) {
+	const n = 10
+	for _, config := range filterConfigs( allConfigs( 100 ), concurrentConfig ) {
+		var q flushTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		if err := q.WaitEmpty( context.Background() ); err != nil {
+			t.Errorf( "WaitEmpty on empty queue failed with flags %x: %s", config.Flags, err )
+		}
+		for i := 0; i < n; i++ {
+			q.Enqueue( i )
+		}
+		result := waitEmptyAsync( t, q.WaitEmpty )
+		// Elements enqueued after the call must not hold up WaitEmpty.
+		q.Enqueue( n )
+		for i := 0; i < n - 1; i++ {
+			q.Dequeue()
+		}
+		if returnsSoon( result ) {
+			t.Errorf( "WaitEmpty returns early with flags %x", config.Flags )
+		}
+		q.Dequeue()
+		if !returns( result ) {
+			t.Errorf( "WaitEmpty does not return with flags %x", config.Flags )
+		}
+		ctx, cancel := context.WithTimeout( context.Background(), 10 * time.Millisecond )
+		if err := q.WaitEmpty( ctx ); err != context.DeadlineExceeded {
+			t.Errorf( "WaitEmpty returned %v instead of timing out with flags %x", err, config.Flags )
+		}
+		cancel()
+		result = waitEmptyAsync( t, q.WaitEmpty )
+		if xs := q.Drain(); len( xs ) != 1 {
+			t.Errorf( "Drain returned %v with flags %x", xs, config.Flags )
+		}
+		if !returns( result ) {
+			t.Errorf( "WaitEmpty does not return after drain with flags %x", config.Flags )
+		}
+	}
+}
+
+func TestWaitEmptyDiscarded( t *testing.T ) {
+	for _, policy := range []OverflowPolicy{ OverflowDropOldest, OverflowDropNewest, OverflowReject } {
+		var q flushTestQueue
+		if err := Make( &q, DefaultConfig().Bounded( 2 ).Overflow( policy ) ); err != nil {
+			t.Fatalf( "Make failed with policy %d: %s", policy, err )
+		}
+		for i := 0; i < 5; i++ {
+			func() {
+				defer func() {
+					recover()
+				}()
+				q.Enqueue( i )
+			}()
+		}
+		result := waitEmptyAsync( t, q.WaitEmpty )
+		q.Dequeue()
+		if returnsSoon( result ) {
+			t.Errorf( "WaitEmpty returns early with policy %d", policy )
+		}
+		q.Dequeue()
+		if !returns( result ) {
+			t.Errorf( "WaitEmpty does not return with policy %d", policy )
+		}
+	}
+}
+
+func TestWaitEmptyNonConcurrent( t *testing.T ) {
+	var q flushTestQueue
+	if err := Make( &q, DefaultConfig().NonConcurrent() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.Enqueue( 1 )
+	if err := q.WaitEmpty( context.Background() ); err != ErrPending {
+		t.Errorf( "WaitEmpty on non-empty non-concurrent queue returned %v instead of ErrPending", err )
+	}
+	q.Dequeue()
+	if err := q.WaitEmpty( context.Background() ); err != nil {
+		t.Errorf( "WaitEmpty on empty non-concurrent queue failed: %s", err )
+	}
+}
+
+func TestDone( t *testing.T ) {
+	const n = 10
+	var q ackTestQueue
+	if err := Make( &q, DefaultConfig() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	for i := 0; i < n; i++ {
+		q.Enqueue( i )
+	}
+	for i := 0; i < n; i++ {
+		q.Dequeue()
+	}
+	result := waitEmptyAsync( t, q.WaitEmpty )
+	for i := 0; i < n - 1; i++ {
+		q.Done()
+	}
+	if returnsSoon( result ) {
+		t.Error( "WaitEmpty returns before all elements are done" )
+	}
+	q.Done()
+	if !returns( result ) {
+		t.Error( "WaitEmpty does not return after all elements are done" )
+	}
+	defer func() {
+		if r := recover(); r != ErrDone {
+			t.Errorf( "Marking more elements done than dequeued did not panic with ErrDone: %v", r )
+		}
+	}()
+	q.Done()
+}
+
+// clearFlushTestQueue is used to test clearing while waiting for consumption.
+type clearFlushTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Clear func() `queue:"clear"`
+	WaitEmpty func( context.Context ) error `queue:"waitEmpty"`
+}
+
+func TestClearParallelWaitEmpty( t *testing.T ) {
+	const iterations = 5000
+	for _, config := range filterConfigs( allConfigs( 100 ), concurrentConfig, unboundedConfig ) {
+		var q clearFlushTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		done := make( chan struct{} )
+		go func() {
+			defer close( done )
+			for i := 0; i < iterations; i++ {
+				q.Enqueue( i )
+			}
+		}()
+		for cleared := false; !cleared; {
+			select {
+			case <-done:
+				cleared = true
+			default:
+			}
+			q.Clear()
+		}
+		ctx, cancel := context.WithTimeout( context.Background(), 5 * time.Second )
+		if err := q.WaitEmpty( ctx ); err != nil {
+			t.Errorf( "WaitEmpty after clear failed with flags %x: %s", config.Flags, err )
+		}
+		cancel()
+	}
+}
+
+func TestWaitEmptyParallel( t *testing.T ) {
+	const iterations = 20000
+	for _, config := range filterConfigs( allConfigs( 100 ), concurrentConfig, unboundedConfig ) {
+		var q ackTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		seen := make( []int32, iterations )
+		// enqueued is the number of completed enqueue operations.
+		var enqueued int32
+		var wg sync.WaitGroup
+		wg.Add( 2 )
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				q.Enqueue( i )
+				atomic.AddInt32( &enqueued, 1 )
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for received := 0; received < iterations; {
+				x, ok := q.Dequeue()
+				if !ok {
+					continue
+				}
+				atomic.StoreInt32( &seen[x], 1 )
+				q.Done()
+				received++
+			}
+		}()
+		for i := 0; i < 10; i++ {
+			time.Sleep( time.Millisecond )
+			before := int( atomic.LoadInt32( &enqueued ) )
+			if err := q.WaitEmpty( context.Background() ); err != nil {
+				t.Fatalf( "WaitEmpty failed with flags %x: %s", config.Flags, err )
+			}
+			for x := 0; x < before; x++ {
+				if atomic.LoadInt32( &seen[x] ) == 0 {
+					t.Fatalf( "WaitEmpty returns before element %d is done with flags %x", x, config.Flags )
+				}
+			}
+		}
+		wg.Wait()
+	}
+}
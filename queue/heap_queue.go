@@ -0,0 +1,397 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// heapEntry is an element of a heapQueue along with its insertion number.
+type heapEntry struct {
+	value interface{}
+	seq uint64
+}
+
+// heapQueue is a non-concurrent priority queue.
+// The elements are kept in a binary heap ordered by less,
+// so dequeue returns the minimum element.
+// Ties are broken by insertion number,
+// so elements which compare equal are dequeued in FIFO order.
+// The heap is a single slice.
+// Storage beyond the initial capacity is released by halving the slice
+// when at most half of it is used
+// and the unused storage exceeds the shrink ratio.
+type heapQueue struct {
+	entries []heapEntry
+	less func( a, b interface{} ) bool
+	// next is the insertion number of the next element.
+	next uint64
+	// initialCapacity is the capacity which is always kept.
+	initialCapacity int
+	// shrinkRatio is the maximum ratio of unused storage
+	// to the number of elements in the queue.
+	// A negative value means that storage is never released.
+	shrinkRatio float64
+	// limit is the maximum number of elements in the queue.
+	// A value of 0 means the queue is unbounded.
+	limit int
+}
+
+// newHeapQueue creates a new, unbounded heap queue ordered by less.
+func newHeapQueue( less func( a, b interface{} ) bool, initialCapacity int, shrinkRatio float64 ) *heapQueue {
+	if initialCapacity < 1 {
+		initialCapacity = 1
+	}
+
+	return &heapQueue{
+		entries: make( []heapEntry, 0, initialCapacity ),
+		less: less,
+		initialCapacity: initialCapacity,
+		shrinkRatio: shrinkRatio,
+	}
+}
+
+// before reports whether entry a must be dequeued before entry b.
+func ( q *heapQueue ) before( a, b *heapEntry ) bool {
+	if q.less( a.value, b.value ) {
+		return true
+	}
+	if q.less( b.value, a.value ) {
+		return false
+	}
+
+	return a.seq < b.seq
+}
+
+// up restores the heap property after entry i has been added.
+func ( q *heapQueue ) up( i int ) {
+	for i > 0 {
+		parent := ( i - 1 ) / 2
+		if !q.before( &q.entries[i], &q.entries[parent] ) {
+			return
+		}
+		q.entries[i], q.entries[parent] = q.entries[parent], q.entries[i]
+		i = parent
+	}
+}
+
+// down restores the heap property after entry i has been replaced.
+func ( q *heapQueue ) down( i int ) {
+	n := len( q.entries )
+	for {
+		min := i
+		for _, child := range [2]int{ 2 * i + 1, 2 * i + 2 } {
+			if ( child < n ) && q.before( &q.entries[child], &q.entries[min] ) {
+				min = child
+			}
+		}
+		if min == i {
+			return
+		}
+		q.entries[i], q.entries[min] = q.entries[min], q.entries[i]
+		i = min
+	}
+}
+
+// shrink releases half of the storage if possible (see heapQueue).
+func ( q *heapQueue ) shrink() {
+	if q.shrinkRatio < 0 {
+		return
+	}
+	n := len( q.entries )
+	size := cap( q.entries ) / 2
+	if size < q.initialCapacity {
+		size = q.initialCapacity
+	}
+	if ( n > size ) || ( size == cap( q.entries ) ) || ( float64( cap( q.entries ) - n ) <= q.shrinkRatio * float64( n ) ) {
+		return
+	}
+	entries := make( []heapEntry, n, size )
+	copy( entries, q.entries )
+	q.entries = entries
+}
+
+// tryEnqueue enqueues x unless the queue is full.
+// The return value indicates whether x has been enqueued.
+func ( q *heapQueue ) tryEnqueue( x interface{} ) bool {
+	if ( q.limit > 0 ) && ( len( q.entries ) >= q.limit ) {
+		return false
+	}
+	q.entries = append( q.entries, heapEntry{
+		value: x,
+		seq: q.next,
+	} )
+	q.next++
+	q.up( len( q.entries ) - 1 )
+
+	return true
+}
+
+func ( q *heapQueue ) enqueue( x interface{} ) {
+	if !q.tryEnqueue( x ) {
+		panic( ErrFull )
+	}
+}
+
+func ( q *heapQueue ) dequeue() ( x interface{}, ok bool ) {
+	n := len( q.entries )
+	if n == 0 {
+		ok = false
+		return
+	}
+	x = q.entries[0].value
+	ok = true
+	q.entries[0] = q.entries[n - 1]
+	q.entries[n - 1] = heapEntry{}
+	q.entries = q.entries[:n - 1]
+	q.down( 0 )
+	q.shrink()
+
+	return
+}
+
+func ( q *heapQueue ) peek() ( x interface{}, ok bool ) {
+	if len( q.entries ) == 0 {
+		ok = false
+		return
+	}
+
+	return q.entries[0].value, true
+}
+
+func ( q *heapQueue ) count() int {
+	return len( q.entries )
+}
+
+func ( q *heapQueue ) capacity() int {
+	return cap( q.entries )
+}
+
+// snapshot lists the elements in the order in which they would be dequeued.
+func ( q *heapQueue ) snapshot() []interface{} {
+	entries := make( []heapEntry, len( q.entries ) )
+	copy( entries, q.entries )
+	sort.Slice( entries, func( i, j int ) bool {
+		return q.before( &entries[i], &entries[j] )
+	} )
+	xs := make( []interface{}, len( entries ) )
+	for i := range entries {
+		xs[i] = entries[i].value
+	}
+
+	return xs
+}
+
+// drain works like snapshot,
+// but leaves the queue empty.
+func ( q *heapQueue ) drain() []interface{} {
+	xs := q.snapshot()
+	for i := range q.entries {
+		q.entries[i] = heapEntry{}
+	}
+	q.entries = q.entries[:0]
+	q.shrink()
+
+	return xs
+}
+
+// clear starts over with the initial capacity.
+func ( q *heapQueue ) clear() int {
+	n := len( q.entries )
+	q.entries = make( []heapEntry, 0, q.initialCapacity )
+
+	return n
+}
+
+// lockedHeapQueue uses a mutex to make heapQueue totally thread-safe.
+type lockedHeapQueue struct {
+	heapQueue
+	mx sync.Mutex
+}
+
+// newLockedHeapQueue creates a new locked heap queue.
+// The arguments are passed on to newHeapQueue.
+func newLockedHeapQueue( less func( a, b interface{} ) bool, initialCapacity int, shrinkRatio float64 ) *lockedHeapQueue {
+	return &lockedHeapQueue{
+		heapQueue: *newHeapQueue( less, initialCapacity, shrinkRatio ),
+		mx: sync.Mutex{},
+	}
+}
+
+func ( q *lockedHeapQueue ) tryEnqueue( x interface{} ) bool {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.tryEnqueue( x )
+}
+
+func ( q *lockedHeapQueue ) enqueue( x interface{} ) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	q.heapQueue.enqueue( x )
+}
+
+func ( q *lockedHeapQueue ) dequeue() ( interface{}, bool ) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.dequeue()
+}
+
+func ( q *lockedHeapQueue ) enqueueBatch( xs []interface{} ) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	for _, x := range xs {
+		q.heapQueue.enqueue( x )
+	}
+}
+
+func ( q *lockedHeapQueue ) dequeueBatch( dst []interface{} ) int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return dequeueBatch( &q.heapQueue, dst )
+}
+
+func ( q *lockedHeapQueue ) count() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.count()
+}
+
+func ( q *lockedHeapQueue ) capacity() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.capacity()
+}
+
+func ( q *lockedHeapQueue ) peek() ( interface{}, bool ) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.peek()
+}
+
+func ( q *lockedHeapQueue ) snapshot() []interface{} {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.snapshot()
+}
+
+func ( q *lockedHeapQueue ) drain() []interface{} {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.drain()
+}
+
+func ( q *lockedHeapQueue ) clear() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.heapQueue.clear()
+}
+
+// makeLess adapts the typed ordering function less to heapQueue.
+// Sequenced elements are unwrapped (see elementValue).
+func makeLess( less reflect.Value ) func( a, b interface{} ) bool {
+	elementType := less.Type().In( 0 )
+	return func( a, b interface{} ) bool {
+		return less.Call( []reflect.Value{
+			elementValue( a, true, elementType ),
+			elementValue( b, true, elementType ),
+		} )[0].Bool()
+	}
+}
+
+// heapQueueFactory implements factory for heapQueue and lockedHeapQueue
+type heapQueueFactory struct {
+	less func( a, b interface{} ) bool
+	initialCapacity int
+	limit int
+	overflow OverflowPolicy
+	shrinkRatio float64
+	// concurrent selects lockedHeapQueue over heapQueue.
+	concurrent bool
+	// q is the heap queue, wrapped in an overflowQueue if it is bounded.
+	q interfaceQueue
+}
+
+func ( hqf *heapQueueFactory ) prepare() {
+	var hq boundedInterfaceQueue
+	if hqf.concurrent {
+		lhq := newLockedHeapQueue( hqf.less, hqf.initialCapacity, hqf.shrinkRatio )
+		lhq.limit = hqf.limit
+		hq = lhq
+	} else {
+		shq := newHeapQueue( hqf.less, hqf.initialCapacity, hqf.shrinkRatio )
+		shq.limit = hqf.limit
+		hq = shq
+	}
+	if hqf.limit > 0 {
+		hqf.q = newOverflowQueue( hq, hqf.overflow )
+	} else {
+		hqf.q = hq
+	}
+}
+
+func ( hqf *heapQueueFactory ) commit() {
+	// empty
+}
+
+func ( hqf *heapQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( hqf.q, methodType )
+}
+
+func ( hqf *heapQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( hqf.q, methodType )
+}
+
+func ( hqf *heapQueueFactory ) queue() interfaceQueue {
+	return hqf.q
+}
+
+func ( hqf *heapQueueFactory ) reset() {
+	hqf.q = nil
+}
+
+// newHeapQueueFactory creates a factory for priority queues
+// ordered by less for the given configuration.
+// Priority queues are not lock-free and do not split locks,
+// and they cannot use registered implementations.
+// If the configuration asks for any of this, nil is returned.
+func newHeapQueueFactory( c *Config, less func( a, b interface{} ) bool ) factory {
+	if ( ( c.Flags & ( FLockFree | FSplitLock | FNotImplemented ) ) != 0 ) || ( c.implementation != "" ) {
+		return nil
+	}
+	initialCapacity := c.initialCapacity
+	if ( c.limit > 0 ) && ( initialCapacity > c.limit ) {
+		initialCapacity = c.limit
+	}
+
+	return &heapQueueFactory{
+		less: less,
+		initialCapacity: initialCapacity,
+		limit: c.limit,
+		overflow: c.overflow,
+		shrinkRatio: c.effectiveShrinkRatio(),
+		concurrent: ( c.Flags & FNonConcurrent ) == 0,
+		q: nil,
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// prioItem is an element of the priority queues under test.
+// Items are ordered by key only,
+// so id tells items with equal keys apart.
+type prioItem struct {
+	key int
+	id int
+}
+
+func prioLess( a, b prioItem ) bool {
+	return a.key < b.key
+}
+
+// prioTestQueue is used to test priority queues.
+type prioTestQueue struct {
+	Enqueue func( prioItem ) `queue:"enqueue"`
+	Dequeue func() ( prioItem, bool ) `queue:"dequeue"`
+	Peek func() ( prioItem, bool ) `queue:"peek"`
+	Len func() int `queue:"len"`
+	Cap func() int `queue:"cap"`
+	Drain func() []prioItem `queue:"drain"`
+	Less func( a, b prioItem ) bool `queue:"less"`
+}
+
+// prioItems returns n items with few distinct keys in random order.
+// The ids count up.
+func prioItems( n int ) []prioItem {
+	items := make( []prioItem, n )
+	for i := range items {
+		items[i] = prioItem{
+			key: rand.Intn( n / 10 + 1 ),
+			id: i,
+		}
+	}
+
+	return items
+}
+
+// sortedPrioItems returns items in the expected dequeue order.
+func sortedPrioItems( items []prioItem ) []prioItem {
+	sorted := make( []prioItem, len( items ) )
+	copy( sorted, items )
+	sort.SliceStable( sorted, func( i, j int ) bool {
+		return prioLess( sorted[i], sorted[j] )
+	} )
+
+	return sorted
+}
+
+func TestHeapQueue( t *testing.T ) {
+	items := prioItems( 1000 )
+	q := newHeapQueue( func( a, b interface{} ) bool {
+		return prioLess( a.( prioItem ), b.( prioItem ) )
+	}, 0, 1 )
+	for _, item := range items {
+		q.enqueue( item )
+	}
+	for i, expected := range sortedPrioItems( items ) {
+		x, ok := q.dequeue()
+		if !ok || ( x != expected ) {
+			t.Fatalf( "Dequeue %d returned %v, %t, expected %v", i, x, ok, expected )
+		}
+	}
+	if _, ok := q.dequeue(); ok {
+		t.Error( "Dequeue from empty heap queue succeeded" )
+	}
+	if q.capacity() != 1 {
+		t.Errorf( "Heap queue did not shrink: capacity %d", q.capacity() )
+	}
+}
+
+func TestPriority( t *testing.T ) {
+	items := prioItems( 1000 )
+	sorted := sortedPrioItems( items )
+	for _, config := range filterConfigs( allConfigs( 1000 ), lockingConfig, unpinnedConfig ) {
+		q := prioTestQueue{
+			Less: prioLess,
+		}
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		for _, item := range items {
+			q.Enqueue( item )
+		}
+		if x, ok := q.Peek(); !ok || ( x != sorted[0] ) {
+			t.Errorf( "Peek returned %v, %t, expected %v with flags %x", x, ok, sorted[0], config.Flags )
+		}
+		for i := 0; i < len( sorted ) / 2; i++ {
+			if x, ok := q.Dequeue(); !ok || ( x != sorted[i] ) {
+				t.Fatalf( "Dequeue %d returned %v, %t, expected %v with flags %x", i, x, ok, sorted[i], config.Flags )
+			}
+		}
+		rest := q.Drain()
+		if len( rest ) != len( sorted ) / 2 {
+			t.Fatalf( "Drain returned %d elements, expected %d with flags %x", len( rest ), len( sorted ) / 2, config.Flags )
+		}
+		for i, x := range rest {
+			if x != sorted[len( sorted ) / 2 + i] {
+				t.Fatalf( "Drain returned %v at %d, expected %v with flags %x", x, i, sorted[len( sorted ) / 2 + i], config.Flags )
+			}
+		}
+		if q.Len() != 0 {
+			t.Errorf( "Drained priority queue has length %d with flags %x", q.Len(), config.Flags )
+		}
+	}
+}
+
+func TestPriorityConfig( t *testing.T ) {
+	var q prioTestQueue
+	if err := Make( &q, DefaultConfig().Priority( prioLess ) ); err != nil {
+		t.Fatal( err )
+	}
+	q.Enqueue( prioItem{ 2, 0 } )
+	q.Enqueue( prioItem{ 1, 1 } )
+	q.Enqueue( prioItem{ 1, 2 } )
+	for _, expected := range []prioItem{ { 1, 1 }, { 1, 2 }, { 2, 0 } } {
+		if x, ok := q.Dequeue(); !ok || ( x != expected ) {
+			t.Errorf( "Dequeue returned %v, %t, expected %v", x, ok, expected )
+		}
+	}
+	// The less function of the structure takes precedence.
+	q = prioTestQueue{
+		Less: func( a, b prioItem ) bool {
+			return a.key > b.key
+		},
+	}
+	if err := Make( &q, DefaultConfig().Priority( prioLess ) ); err != nil {
+		t.Fatal( err )
+	}
+	q.Enqueue( prioItem{ 1, 0 } )
+	q.Enqueue( prioItem{ 2, 1 } )
+	if x, _ := q.Dequeue(); x.key != 2 {
+		t.Errorf( "Dequeue returned %v, expected key 2", x )
+	}
+	q.Less = nil
+	if err := Make( &q, DefaultConfig().Priority( prioLess ).LockFree() ); err == nil {
+		t.Error( "Make succeeded for lock-free priority queue" )
+	}
+	if err := Make( &q, DefaultConfig().Priority( func( a, b int ) bool { return a < b } ) ); err == nil {
+		t.Error( "Make succeeded despite element type mismatch of priority function" )
+	}
+	if err := Make( &q, DefaultConfig().Priority( 42 ) ); err == nil {
+		t.Error( "Make succeeded with priority function not being a function" )
+	}
+}
+
+func TestPriorityOverflow( t *testing.T ) {
+	q := prioTestQueue{
+		Less: prioLess,
+	}
+	if err := Make( &q, DefaultConfig().Bounded( 2 ).Overflow( OverflowDropOldest ) ); err != nil {
+		t.Fatal( err )
+	}
+	q.Enqueue( prioItem{ 1, 0 } )
+	q.Enqueue( prioItem{ 3, 1 } )
+	// The minimum element is dropped.
+	q.Enqueue( prioItem{ 2, 2 } )
+	for _, expected := range []prioItem{ { 2, 2 }, { 3, 1 } } {
+		if x, ok := q.Dequeue(); !ok || ( x != expected ) {
+			t.Errorf( "Dequeue returned %v, %t, expected %v", x, ok, expected )
+		}
+	}
+}
+
+func TestPriorityConcurrent( t *testing.T ) {
+	const writers = 4
+	const n = 1000
+	q := prioTestQueue{
+		Less: prioLess,
+	}
+	if err := Make( &q, nil ); err != nil {
+		t.Fatal( err )
+	}
+	// Items of each writer have the same key,
+	// so each writer's items must be dequeued in order.
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add( 1 )
+		go func( w int ) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				q.Enqueue( prioItem{ w, i } )
+			}
+		}( w )
+	}
+	next := make( []int, writers )
+	for received := 0; received < writers * n; {
+		x, ok := q.Dequeue()
+		if !ok {
+			runtime.Gosched()
+			continue
+		}
+		if x.id != next[x.key] {
+			t.Fatalf( "Dequeued %v, expected id %d", x, next[x.key] )
+		}
+		next[x.key]++
+		received++
+	}
+	wg.Wait()
+}
+
+func BenchmarkHeapQueueSteady( b *testing.B ) {
+	benchmarkSteady( b, newHeapQueueFactory( DefaultConfig(), func( a, b interface{} ) bool {
+		return false
+	} ) )
+}
@@ -33,6 +33,21 @@ type lockedQueue struct {
 	mx sync.Mutex
 }
 
+// newLockedQueue creates a new locked queue.
+// The arguments are passed on to newSimpleQueue.
+func newLockedQueue( initialCapacity, segmentSize int, shrinkRatio float64 ) *lockedQueue {
+	return &lockedQueue{
+		simpleQueue: *newSimpleQueue( initialCapacity, segmentSize, shrinkRatio ),
+		mx: sync.Mutex{},
+	}
+}
+
+func ( q *lockedQueue ) tryEnqueue( x interface{} ) bool {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.tryEnqueue( x )
+}
+
 func ( q *lockedQueue ) enqueue( x interface{} ) {
 	q.mx.Lock()
 	defer q.mx.Unlock()
@@ -45,16 +60,79 @@ func ( q *lockedQueue ) dequeue() ( interface{}, bool ) {
 	return q.simpleQueue.dequeue()
 }
 
+func ( q *lockedQueue ) enqueueBatch( xs []interface{} ) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	for _, x := range xs {
+		q.simpleQueue.enqueue( x )
+	}
+}
+
+func ( q *lockedQueue ) tryEnqueueBatch( xs []interface{} ) int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.tryEnqueueBatch( xs )
+}
+
+func ( q *lockedQueue ) dequeueBatch( dst []interface{} ) int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return dequeueBatch( &q.simpleQueue, dst )
+}
+
+func ( q *lockedQueue ) count() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.count()
+}
+
+func ( q *lockedQueue ) capacity() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.capacity()
+}
+
+func ( q *lockedQueue ) peek() ( interface{}, bool ) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.peek()
+}
+
+func ( q *lockedQueue ) snapshot() []interface{} {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.snapshot()
+}
+
+func ( q *lockedQueue ) drain() []interface{} {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.drain()
+}
+
+func ( q *lockedQueue ) clear() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.simpleQueue.clear()
+}
+
 // lockedQueueFactory implements factory for lockedQueue
 type lockedQueueFactory struct {
-	dbFactory
+	segFactory
+	limit int
+	overflow OverflowPolicy
 	lq *lockedQueue
+	// q is lq, wrapped in an overflowQueue if lq is bounded.
+	q interfaceQueue
 }
 
 func ( lqf *lockedQueueFactory ) prepare() {
-	lqf.lq = &lockedQueue{
-		simpleQueue: *newSimpleQueue( lqf.capacityPerBuffer ),
-		mx: sync.Mutex{},
+	lqf.lq = newLockedQueue( lqf.initialCapacity, lqf.segmentSize, lqf.shrinkRatio )
+	lqf.lq.limit = lqf.limit
+	if lqf.limit > 0 {
+		lqf.q = newOverflowQueue( lqf.lq, lqf.overflow )
+	} else {
+		lqf.q = lqf.lq
 	}
 }
 
@@ -63,20 +141,35 @@ func ( lqf *lockedQueueFactory ) commit() {
 }
 
 func ( lqf *lockedQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
-	return makeEnqueue( lqf.lq, methodType )
+	return makeEnqueue( lqf.q, methodType )
 }
 
 func( lqf *lockedQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
-	return makeDequeue( lqf.lq, methodType )
+	return makeDequeue( lqf.q, methodType )
+}
+
+func ( lqf *lockedQueueFactory ) queue() interfaceQueue {
+	return lqf.q
 }
 
 func ( lqf *lockedQueueFactory ) reset() {
 	lqf.lq = nil
+	lqf.q = nil
+}
+
+func newLockedQueueFactory( initialCapacity int, shrinkRatio float64 ) factory {
+	return newBoundedLockedQueueFactory( initialCapacity, 0, OverflowReject, shrinkRatio )
 }
 
-func newLockedQueueFactory( initialCapacity int ) factory {
+// newBoundedLockedQueueFactory creates a factory for locked queues
+// holding at most limit elements.
+// A limit of 0 means the queue is unbounded.
+func newBoundedLockedQueueFactory( initialCapacity, limit int, overflow OverflowPolicy, shrinkRatio float64 ) factory {
 	return &lockedQueueFactory{
-		dbFactory: *newDbFactory( initialCapacity ),
+		segFactory: *newSegFactory( initialCapacity, limit, shrinkRatio ),
+		limit: limit,
+		overflow: overflow,
 		lq: nil,
+		q: nil,
 	}
 }
@@ -30,7 +30,7 @@ import(
 )
 
 func TestLockedQueue( t *testing.T ) {
-	f := newLockedQueueFactory( 0 )
+	f := newLockedQueueFactory( 0, DefaultShrinkRatio )
 	f.prepare()
 	var enqueue func( int )
 	var dequeue func() ( int, bool )
@@ -76,3 +76,15 @@ func TestLockedQueue( t *testing.T ) {
 	go reader()
 	wg.Wait()
 }
+
+func BenchmarkLockedQueueSteady( b *testing.B ) {
+	benchmarkSteady( b, newLockedQueueFactory( DefaultInitialCapacity, DefaultShrinkRatio ) )
+}
+
+func BenchmarkLockedQueueBurst( b *testing.B ) {
+	benchmarkBursts( b, newLockedQueueFactory( DefaultInitialCapacity, DefaultShrinkRatio ), 100000 )
+}
+
+func BenchmarkLockedQueueGrowth( b *testing.B ) {
+	benchmarkGrowth( b, newLockedQueueFactory( DefaultInitialCapacity, DefaultShrinkRatio ), 100000 )
+}
@@ -23,9 +23,11 @@ SOFTWARE.
 package queue
 
 import(
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // Make creates a new queue.
@@ -42,7 +44,38 @@ func Make( qptr interface{}, config *Config ) error {
 	const(
 		queue = "queue"
 		enqueue = "enqueue"
+		tryEnqueue = "tryEnqueue"
+		enqueueWait = "enqueueWait"
+		enqueueCtx = "enqueueCtx"
 		dequeue = "dequeue"
+		discarded = "discarded"
+		dequeueWait = "dequeueWait"
+		dequeueCtx = "dequeueCtx"
+		dequeueTimeout = "dequeueTimeout"
+		peek = "peek"
+		length = "len"
+		capacity = "cap"
+		isEmpty = "isEmpty"
+		isFull = "isFull"
+		closeTag = "close"
+		enqueueBatch = "enqueueBatch"
+		dequeueBatch = "dequeueBatch"
+		drain = "drain"
+		clear = "clear"
+		all = "all"
+		consume = "consume"
+		waitEmpty = "waitEmpty"
+		done = "done"
+		ready = "ready"
+		notFull = "notFull"
+		less = "less"
+	)
+	// Types used in signatures
+	var(
+		contextType = reflect.TypeOf( ( *context.Context )( nil ) ).Elem()
+		errorType = reflect.TypeOf( ( *error )( nil ) ).Elem()
+		durationType = reflect.TypeOf( time.Duration( 0 ) )
+		readyType = reflect.TypeOf( ( <-chan struct{} )( nil ) )
 	)
 	// Get config
 	if config == nil {
@@ -51,13 +84,7 @@ func Make( qptr interface{}, config *Config ) error {
 	if !config.IsValid() {
 		return errors.New( "Invalid queue configuration" )
 	}
-	factory := config.factory()
-	if factory == nil {
-		return errors.New( "This queue configuration has not been implemented yet" )
-	}
-	factory.prepare()
-	defer factory.reset()
-	// Extract function pointers
+	// Check structure
 	qptrValue := reflect.ValueOf( qptr )
 	if qptrValue.Kind() != reflect.Ptr {
 		return errors.New( "The argument qptr must be a pointer" )
@@ -67,6 +94,162 @@ func Make( qptr interface{}, config *Config ) error {
 	if qType.Kind() != reflect.Struct {
 		return errors.New( "The argument qptr must be a pointer to a structure" )
 	}
+	// Determine required functionality.
+	waiting := false
+	closing := false
+	peeking := false
+	sizing := false
+	draining := false
+	snapshotting := false
+	flushing := false
+	acknowledging := false
+	sequencing := false
+	// notFulling indicates that writers can wait for space.
+	notFulling := false
+	// tagging indicates that dequeued elements need their sequence numbers.
+	tagging := false
+	var lessValue reflect.Value
+	for i := 0; i != qType.NumField(); i++ {
+		field := qType.Field( i )
+		switch field.Tag.Get( queue ) {
+		case less:
+			if ( field.Type.Kind() == reflect.Func ) && !qValue.Field( i ).IsNil() {
+				lessValue = qValue.Field( i )
+			}
+		case enqueue:
+			if ( field.Type.Kind() == reflect.Func ) && ( field.Type.NumOut() == 1 ) && ( field.Type.Out( 0 ).Kind() == reflect.Uint64 ) {
+				sequencing = true
+			}
+		case dequeue:
+			if ( field.Type.Kind() == reflect.Func ) && ( field.Type.NumOut() == 3 ) {
+				sequencing = true
+				tagging = true
+			}
+		case dequeueWait, dequeueCtx, dequeueTimeout:
+			waiting = true
+		case closeTag:
+			closing = true
+		case peek:
+			peeking = true
+		case length, capacity, isEmpty, isFull:
+			sizing = true
+		case ready:
+			waiting = true
+			sizing = true
+		case notFull:
+			sizing = true
+			notFulling = true
+		case drain, clear:
+			draining = true
+		case all:
+			snapshotting = true
+		case waitEmpty:
+			flushing = true
+		case done:
+			flushing = true
+			acknowledging = true
+		}
+	}
+	// A less function in the structure takes precedence
+	// over the one in the configuration.
+	if !lessValue.IsValid() && ( config.less != nil ) {
+		lessValue = reflect.ValueOf( config.less )
+	}
+	if lessValue.IsValid() {
+		lessType := lessValue.Type()
+		if ( lessType.Kind() != reflect.Func ) || ( lessType.NumIn() != 2 ) || ( lessType.In( 0 ) != lessType.In( 1 ) ) || ( lessType.NumOut() != 1 ) || ( lessType.Out( 0 ).Kind() != reflect.Bool ) {
+			return errors.New( "Ordering function must take two elements of the same type and return bool" )
+		}
+		if lessValue.IsNil() {
+			return errors.New( "Ordering function must not be nil" )
+		}
+	}
+	// Sequence numbers are counted on both ends of a FIFO queue
+	// under one lock for writers and one for readers (see sequenceQueue),
+	// so the queue must be strictly ordered, and cannot be lock-free.
+	if sequencing && ( ( config.Flags & FLockFree ) != 0 ) {
+		return errors.New( "Sequence numbers serialise writers and readers on a lock each, so the queue cannot be lock-free" )
+	}
+	if sequencing && ( ( ( config.Flags & FRelaxedOrder ) != 0 ) || lessValue.IsValid() ) {
+		return errors.New( "Sequence numbers require a strictly ordered FIFO queue" )
+	}
+	// Writers can only wait for space in a concurrent bounded queue
+	// if it applies the overflow policy through an overflowQueue,
+	// which registered implementations do not.
+	waitingForSpace := notFulling && ( ( config.Flags & ( FNonConcurrent | FBounded ) ) == FBounded )
+	// Get factory
+	accept := func( f factory ) bool {
+		f.prepare()
+		if ( peeking && ( asPeekQueue( f.queue() ) == nil ) ) ||
+			( sizing && ( asSizeQueue( f.queue() ) == nil ) ) ||
+			( draining && ( asDrainQueue( f.queue() ) == nil ) ) ||
+			( snapshotting && ( asSnapshotQueue( f.queue() ) == nil ) ) ||
+			( waitingForSpace && ( findOverflowQueue( f.queue() ) == nil ) ) {
+			f.reset()
+			return false
+		}
+		return true
+	}
+	var factory factory = nil
+	if lessValue.IsValid() {
+		factory = newHeapQueueFactory( config, makeLess( lessValue ) )
+		if ( factory != nil ) && !accept( factory ) {
+			factory = nil
+		}
+	} else {
+		factory = config.factoryFor( accept )
+	}
+	if factory == nil {
+		if config.implementation != "" {
+			return fmt.Errorf( "Implementation '%s' is not registered or does not support this queue configuration and structure", config.implementation )
+		}
+		return errors.New( "This queue configuration has not been implemented yet" )
+	}
+	defer factory.reset()
+	limit := 0
+	if ( config.Flags & FBounded ) != 0 {
+		limit = config.limit
+	}
+	// Add functionality by wrapping the queue.
+	// Consumers waiting for elements must be notified on enqueue.
+	// Non-concurrent queues cannot be waited on.
+	q := factory.queue()
+	// Sequence numbers are assigned below the overflow policy,
+	// so that blocked writers do not hold up the others.
+	if sequencing {
+		if oq, ok := q.( *overflowQueue ); ok {
+			oq.queue = newSequenceQueue( oq.queue, tagging )
+		} else {
+			q = newSequenceQueue( q, tagging )
+		}
+	}
+	// Consumed elements are counted below the overflow policy,
+	// so that discarded elements are counted as well.
+	var fq *flushQueue = nil
+	if flushing {
+		if oq, ok := q.( *overflowQueue ); ok {
+			fq = newFlushQueue( oq.queue, acknowledging )
+			oq.queue = fq
+		} else {
+			fq = newFlushQueue( q, acknowledging )
+			q = fq
+		}
+	}
+	var cq *closeQueue = nil
+	if closing {
+		cq = newCloseQueue( q )
+		if oq, ok := q.( *overflowQueue ); ok {
+			oq.done = cq.done
+			cq.onClose = append( cq.onClose, oq.writable.notify )
+		}
+		q = cq
+	}
+	if waiting && ( ( config.Flags & FNonConcurrent ) == 0 ) {
+		q = newWaitQueue( q )
+	}
+	wrapped := q != factory.queue()
+	concurrent := ( config.Flags & FNonConcurrent ) == 0
+	// Extract function pointers
 	haveEnqueue := false
 	haveDequeue := false
 	var elementType reflect.Type = nil
@@ -74,7 +257,7 @@ func Make( qptr interface{}, config *Config ) error {
 		field := qType.Field( i )
 		tagstring := field.Tag.Get( queue )
 		switch tagstring {
-		case enqueue:
+		case enqueueWait:
 			if field.Type.Kind() != reflect.Func {
 				return fmt.Errorf( "Field '%s' must be a function", field.Name )
 			}
@@ -91,7 +274,58 @@ func Make( qptr interface{}, config *Config ) error {
 					return fmt.Errorf( "Argument to function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.In( 0 ).Name(), elementType.Name() )
 				}
 			}
-			qValue.Field( i ).Set( factory.makeEnqueue( field.Type ) )
+			qValue.Field( i ).Set( makeEnqueueWait( q, concurrent, field.Type ) )
+		case enqueueCtx:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 2 {
+				return fmt.Errorf( "Function '%s' must take exactly two arguments", field.Name )
+			}
+			if field.Type.In( 0 ) != contextType {
+				return fmt.Errorf( "First argument to function '%s' must have type context.Context", field.Name )
+			}
+			if ( field.Type.NumOut() != 1 ) || ( field.Type.Out( 0 ) != errorType ) {
+				return fmt.Errorf( "Function '%s' must return exactly one error", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.In( 1 )
+			} else {
+				if elementType != field.Type.In( 1 ) {
+					return fmt.Errorf( "Second argument to function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.In( 1 ).Name(), elementType.Name() )
+				}
+			}
+			qValue.Field( i ).Set( makeEnqueueCtx( q, concurrent, field.Type ) )
+		case enqueue, tryEnqueue:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 1 {
+				return fmt.Errorf( "Function '%s' must take exactly one argument", field.Name )
+			}
+			if tagstring == tryEnqueue {
+				if ( field.Type.NumOut() != 1 ) || ( field.Type.Out( 0 ).Kind() != reflect.Bool ) {
+					return fmt.Errorf( "Function '%s' must return exactly one value of type bool", field.Name )
+				}
+			} else if ( field.Type.NumOut() > 1 ) || ( ( field.Type.NumOut() == 1 ) && ( field.Type.Out( 0 ) != errorType ) && ( field.Type.Out( 0 ).Kind() != reflect.Uint64 ) ) {
+				return fmt.Errorf( "Function '%s' must return nothing, an error, or a value of type uint64", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.In( 0 )
+			} else {
+				if elementType != field.Type.In( 0 ) {
+					return fmt.Errorf( "Argument to function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.In( 0 ).Name(), elementType.Name() )
+				}
+			}
+			if tagstring == tryEnqueue {
+				qValue.Field( i ).Set( makeTryEnqueue( q, field.Type ) )
+			} else if sequencing && ( field.Type.NumOut() == 1 ) && ( field.Type.Out( 0 ) != errorType ) {
+				qValue.Field( i ).Set( makeEnqueueSeq( q, field.Type ) )
+			} else if wrapped || ( field.Type.NumOut() != 0 ) {
+				qValue.Field( i ).Set( makeEnqueue( q, field.Type ) )
+			} else {
+				qValue.Field( i ).Set( factory.makeEnqueue( field.Type ) )
+			}
 			haveEnqueue = true
 		case dequeue:
 			if field.Type.Kind() != reflect.Func {
@@ -100,6 +334,101 @@ func Make( qptr interface{}, config *Config ) error {
 			if field.Type.NumIn() != 0 {
 				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
 			}
+			if ( field.Type.NumOut() < 2 ) || ( field.Type.NumOut() > 3 ) {
+				return fmt.Errorf( "Function '%s' must return two or three values", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.Out( 0 )
+			} else {
+				if elementType != field.Type.Out( 0 ) {
+					return fmt.Errorf( "First return value of function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.Out( 0 ).Name(), elementType.Name() )
+				}
+			}
+			if field.Type.NumOut() == 3 {
+				if field.Type.Out( 1 ).Kind() != reflect.Uint64 {
+					return fmt.Errorf( "Second return value of function '%s' must have type uint64", field.Name )
+				}
+				if field.Type.Out( 2 ).Kind() != reflect.Bool {
+					return fmt.Errorf( "Third return value of function '%s' must have type bool", field.Name )
+				}
+				qValue.Field( i ).Set( makeDequeueSeq( q, field.Type ) )
+			} else if ( field.Type.Out( 1 ).Kind() != reflect.Bool ) && ( field.Type.Out( 1 ) != errorType ) {
+				return fmt.Errorf( "Second return value of function '%s' must have type bool or error", field.Name )
+			} else if wrapped || ( field.Type.Out( 1 ) == errorType ) {
+				qValue.Field( i ).Set( makeDequeue( q, field.Type ) )
+			} else {
+				qValue.Field( i ).Set( factory.makeDequeue( field.Type ) )
+			}
+			haveDequeue = true
+		case discarded:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 1 {
+				return fmt.Errorf( "Function '%s' must return exactly one value", field.Name )
+			}
+			if field.Type.Out( 0 ).Kind() != reflect.Uint64 {
+				return fmt.Errorf( "Return value of function '%s' must have type uint64", field.Name )
+			}
+			qValue.Field( i ).Set( makeDiscarded( factory.queue(), field.Type ) )
+		case dequeueWait:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if ( field.Type.NumOut() < 1 ) || ( field.Type.NumOut() > 2 ) {
+				return fmt.Errorf( "Function '%s' must return one or two values", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.Out( 0 )
+			} else {
+				if elementType != field.Type.Out( 0 ) {
+					return fmt.Errorf( "First return value of function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.Out( 0 ).Name(), elementType.Name() )
+				}
+			}
+			if ( field.Type.NumOut() == 2 ) && ( field.Type.Out( 1 ).Kind() != reflect.Bool ) {
+				return fmt.Errorf( "Second return value of function '%s' must have type bool", field.Name )
+			}
+			qValue.Field( i ).Set( makeDequeueWait( q, field.Type ) )
+		case dequeueCtx:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 1 {
+				return fmt.Errorf( "Function '%s' must take exactly one argument", field.Name )
+			}
+			if field.Type.In( 0 ) != contextType {
+				return fmt.Errorf( "Argument to function '%s' must have type context.Context", field.Name )
+			}
+			if field.Type.NumOut() != 2 {
+				return fmt.Errorf( "Function '%s' must return exactly two values", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.Out( 0 )
+			} else {
+				if elementType != field.Type.Out( 0 ) {
+					return fmt.Errorf( "First return value of function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.Out( 0 ).Name(), elementType.Name() )
+				}
+			}
+			if field.Type.Out( 1 ) != errorType {
+				return fmt.Errorf( "Second return value of function '%s' must have type error", field.Name )
+			}
+			qValue.Field( i ).Set( makeDequeueCtx( q, field.Type ) )
+		case dequeueTimeout:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 1 {
+				return fmt.Errorf( "Function '%s' must take exactly one argument", field.Name )
+			}
+			if field.Type.In( 0 ) != durationType {
+				return fmt.Errorf( "Argument to function '%s' must have type time.Duration", field.Name )
+			}
 			if field.Type.NumOut() != 2 {
 				return fmt.Errorf( "Function '%s' must return exactly two values", field.Name )
 			}
@@ -113,8 +442,225 @@ func Make( qptr interface{}, config *Config ) error {
 			if field.Type.Out( 1 ).Kind() != reflect.Bool {
 				return fmt.Errorf( "Second return value of function '%s' must have type bool", field.Name )
 			}
-			qValue.Field( i ).Set( factory.makeDequeue( field.Type ) )
-			haveDequeue = true
+			qValue.Field( i ).Set( makeDequeueTimeout( q, field.Type ) )
+		case peek:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 2 {
+				return fmt.Errorf( "Function '%s' must return exactly two values", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.Out( 0 )
+			} else {
+				if elementType != field.Type.Out( 0 ) {
+					return fmt.Errorf( "First return value of function '%s' has wrong type '%s', expected '%s'", field.Name, field.Type.Out( 0 ).Name(), elementType.Name() )
+				}
+			}
+			if field.Type.Out( 1 ).Kind() != reflect.Bool {
+				return fmt.Errorf( "Second return value of function '%s' must have type bool", field.Name )
+			}
+			qValue.Field( i ).Set( makePeek( factory.queue(), field.Type ) )
+		case length, capacity:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 1 {
+				return fmt.Errorf( "Function '%s' must return exactly one value", field.Name )
+			}
+			if field.Type.Out( 0 ).Kind() != reflect.Int {
+				return fmt.Errorf( "Return value of function '%s' must have type int", field.Name )
+			}
+			if tagstring == length {
+				qValue.Field( i ).Set( makeLen( factory.queue(), field.Type ) )
+			} else {
+				qValue.Field( i ).Set( makeCap( factory.queue(), limit, field.Type ) )
+			}
+		case isEmpty, isFull:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 1 {
+				return fmt.Errorf( "Function '%s' must return exactly one value", field.Name )
+			}
+			if field.Type.Out( 0 ).Kind() != reflect.Bool {
+				return fmt.Errorf( "Return value of function '%s' must have type bool", field.Name )
+			}
+			if tagstring == isEmpty {
+				qValue.Field( i ).Set( makeIsEmpty( factory.queue(), field.Type ) )
+			} else {
+				qValue.Field( i ).Set( makeIsFull( factory.queue(), limit, field.Type ) )
+			}
+		case enqueueBatch:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 1 {
+				return fmt.Errorf( "Function '%s' must take exactly one argument", field.Name )
+			}
+			if field.Type.In( 0 ).Kind() != reflect.Slice {
+				return fmt.Errorf( "Argument to function '%s' must be a slice", field.Name )
+			}
+			if field.Type.NumOut() != 0 {
+				return fmt.Errorf( "Function '%s' must not return anything", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.In( 0 ).Elem()
+			} else {
+				if elementType != field.Type.In( 0 ).Elem() {
+					return fmt.Errorf( "Argument to function '%s' has wrong element type '%s', expected '%s'", field.Name, field.Type.In( 0 ).Elem().Name(), elementType.Name() )
+				}
+			}
+			qValue.Field( i ).Set( makeEnqueueBatch( q, field.Type ) )
+		case dequeueBatch:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 1 {
+				return fmt.Errorf( "Function '%s' must take exactly one argument", field.Name )
+			}
+			if ( field.Type.In( 0 ).Kind() != reflect.Slice ) || field.Type.IsVariadic() {
+				return fmt.Errorf( "Argument to function '%s' must be a non-variadic slice", field.Name )
+			}
+			if field.Type.NumOut() != 1 {
+				return fmt.Errorf( "Function '%s' must return exactly one value", field.Name )
+			}
+			if field.Type.Out( 0 ).Kind() != reflect.Int {
+				return fmt.Errorf( "Return value of function '%s' must have type int", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.In( 0 ).Elem()
+			} else {
+				if elementType != field.Type.In( 0 ).Elem() {
+					return fmt.Errorf( "Argument to function '%s' has wrong element type '%s', expected '%s'", field.Name, field.Type.In( 0 ).Elem().Name(), elementType.Name() )
+				}
+			}
+			qValue.Field( i ).Set( makeDequeueBatch( q, field.Type ) )
+		case closeTag:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 0 {
+				return fmt.Errorf( "Function '%s' must not return anything", field.Name )
+			}
+			qValue.Field( i ).Set( makeClose( cq, field.Type ) )
+		case drain:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 1 {
+				return fmt.Errorf( "Function '%s' must return exactly one value", field.Name )
+			}
+			if field.Type.Out( 0 ).Kind() != reflect.Slice {
+				return fmt.Errorf( "Return value of function '%s' must be a slice", field.Name )
+			}
+			if elementType == nil {
+				elementType = field.Type.Out( 0 ).Elem()
+			} else {
+				if elementType != field.Type.Out( 0 ).Elem() {
+					return fmt.Errorf( "Return value of function '%s' has wrong element type '%s', expected '%s'", field.Name, field.Type.Out( 0 ).Elem().Name(), elementType.Name() )
+				}
+			}
+			qValue.Field( i ).Set( makeDrain( q, field.Type ) )
+		case clear:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 0 {
+				return fmt.Errorf( "Function '%s' must not return anything", field.Name )
+			}
+			qValue.Field( i ).Set( makeClear( q, field.Type ) )
+		case waitEmpty:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if ( field.Type.NumIn() != 1 ) || ( field.Type.In( 0 ) != contextType ) {
+				return fmt.Errorf( "Function '%s' must take exactly one argument of type context.Context", field.Name )
+			}
+			if ( field.Type.NumOut() != 1 ) || ( field.Type.Out( 0 ) != errorType ) {
+				return fmt.Errorf( "Function '%s' must return exactly one error", field.Name )
+			}
+			qValue.Field( i ).Set( makeWaitEmpty( fq, concurrent, field.Type ) )
+		case done:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if field.Type.NumOut() != 0 {
+				return fmt.Errorf( "Function '%s' must not return anything", field.Name )
+			}
+			qValue.Field( i ).Set( makeDone( fq, field.Type ) )
+		case ready, notFull:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 0 {
+				return fmt.Errorf( "Function '%s' must not take any arguments", field.Name )
+			}
+			if ( field.Type.NumOut() != 1 ) || ( field.Type.Out( 0 ) != readyType ) {
+				return fmt.Errorf( "Function '%s' must return exactly one value of type <-chan struct{}", field.Name )
+			}
+			if tagstring == ready {
+				qValue.Field( i ).Set( makeReady( q, field.Type ) )
+			} else {
+				qValue.Field( i ).Set( makeNotFull( q, limit, ( config.Flags & FNonConcurrent ) == 0, field.Type ) )
+			}
+		case all, consume:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 1 {
+				return fmt.Errorf( "Function '%s' must take exactly one argument", field.Name )
+			}
+			if field.Type.NumOut() != 0 {
+				return fmt.Errorf( "Function '%s' must not return anything", field.Name )
+			}
+			yieldType := field.Type.In( 0 )
+			if ( yieldType.Kind() != reflect.Func ) || ( yieldType.NumIn() != 1 ) || ( yieldType.NumOut() != 1 ) || ( yieldType.Out( 0 ).Kind() != reflect.Bool ) {
+				return fmt.Errorf( "Argument to function '%s' must be a function taking one element and returning bool", field.Name )
+			}
+			if elementType == nil {
+				elementType = yieldType.In( 0 )
+			} else {
+				if elementType != yieldType.In( 0 ) {
+					return fmt.Errorf( "Argument to function '%s' takes wrong element type '%s', expected '%s'", field.Name, yieldType.In( 0 ).Name(), elementType.Name() )
+				}
+			}
+			if tagstring == all {
+				qValue.Field( i ).Set( makeAll( q, field.Type ) )
+			} else {
+				qValue.Field( i ).Set( makeConsume( q, field.Type ) )
+			}
+		case less:
+			if field.Type.Kind() != reflect.Func {
+				return fmt.Errorf( "Field '%s' must be a function", field.Name )
+			}
+			if field.Type.NumIn() != 2 {
+				return fmt.Errorf( "Function '%s' must take exactly two arguments", field.Name )
+			}
+			if ( field.Type.NumOut() != 1 ) || ( field.Type.Out( 0 ).Kind() != reflect.Bool ) {
+				return fmt.Errorf( "Function '%s' must return exactly one value of type bool", field.Name )
+			}
 		default:
 			continue
 		}
@@ -122,6 +668,9 @@ func Make( qptr interface{}, config *Config ) error {
 	if !haveEnqueue || !haveDequeue {
 		return errors.New( "Passed structure must have enqueue and dequeue tags" )
 	}
+	if lessValue.IsValid() && ( lessValue.Type().In( 0 ) != elementType ) {
+		return fmt.Errorf( "Ordering function takes wrong element type '%s', expected '%s'", lessValue.Type().In( 0 ).Name(), elementType.Name() )
+	}
 	factory.commit()
 
 	return nil
@@ -23,7 +23,9 @@ SOFTWARE.
 package queue
 
 import(
+	"context"
 	"testing"
+	"time"
 )
 
 type structEmpty struct {
@@ -107,6 +109,164 @@ type structBadDequeue5 struct {
 	Dequeue func( int ) ( int, bool ) `queue:"dequeue"`
 }
 
+type structBadDiscarded struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Discarded func() int `queue:"discarded"`
+}
+
+type structBadDequeueWait1 struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueWait func() ( int, int ) `queue:"dequeueWait"`
+}
+
+type structBadDequeueWait2 struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueWait func() float64 `queue:"dequeueWait"`
+}
+
+type structBadDequeueCtx1 struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueCtx func() ( int, error ) `queue:"dequeueCtx"`
+}
+
+type structBadDequeueCtx2 struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueCtx func( context.Context ) ( int, bool ) `queue:"dequeueCtx"`
+}
+
+type structBadDequeueTimeout1 struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueTimeout func( int64 ) ( int, bool ) `queue:"dequeueTimeout"`
+}
+
+type structBadDequeueTimeout2 struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueTimeout func( time.Duration ) int `queue:"dequeueTimeout"`
+}
+
+type structBadLen struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Len func() uint `queue:"len"`
+}
+
+type structBadIsFull struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	IsFull func() int `queue:"isFull"`
+}
+
+type structBadEnqueueBatch struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	EnqueueBatch func( int ) `queue:"enqueueBatch"`
+}
+
+type structBadDequeueBatch struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueBatch func( []int ) `queue:"dequeueBatch"`
+}
+
+type structBatchTypeMismatch struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	EnqueueBatch func( []float64 ) `queue:"enqueueBatch"`
+}
+
+type structBadDrain struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Drain func() int `queue:"drain"`
+}
+
+type structBadClear struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Clear func() bool `queue:"clear"`
+}
+
+type structBadAll struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	All func( func( int ) ) `queue:"all"`
+}
+
+type structBadConsume struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Consume func( func( float64 ) bool ) `queue:"consume"`
+}
+
+type structBadTryEnqueue struct {
+	TryEnqueue func( int ) error `queue:"tryEnqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+}
+
+type structTryEnqueueOnly struct {
+	TryEnqueue func( int ) bool `queue:"tryEnqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+}
+
+type structBadEnqueueWait struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	EnqueueWait func( int ) bool `queue:"enqueueWait"`
+}
+
+type structBadEnqueueCtx struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	EnqueueCtx func( int, context.Context ) error `queue:"enqueueCtx"`
+}
+
+type structBadWaitEmpty struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	WaitEmpty func() error `queue:"waitEmpty"`
+}
+
+type structBadDone struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Done func( int ) `queue:"done"`
+}
+
+type structBadReady struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Ready func() chan struct{} `queue:"ready"`
+}
+
+type structBadSeqEnqueue struct {
+	Enqueue func( int ) int `queue:"enqueue"`
+	Dequeue func() ( int, uint64, bool ) `queue:"dequeue"`
+}
+
+type structBadSeqDequeue struct {
+	Enqueue func( int ) uint64 `queue:"enqueue"`
+	Dequeue func() ( int, int, bool ) `queue:"dequeue"`
+}
+
+type structBadLess struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Less func( a, b int ) int `queue:"less"`
+}
+
+type structLessTypeMismatch struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Less func( a, b float64 ) bool `queue:"less"`
+}
+
 type structTypeMismatch1 struct {
 	Enqueue func( int ) `queue:"enqueue"`
 	Dequeue func()( float64, bool ) `queue:"dequeue"`
@@ -247,6 +407,149 @@ func TestMake( t *testing.T ) {
 	if err == nil {
 		t.Error( "Make succeeded despite dequeue taking an argument" )
 	}
+	var sbdc structBadDiscarded
+	err = Make( &sbdc, config )
+	if err == nil {
+		t.Error( "Make succeeded despite discarded returning value not of type uint64" )
+	}
+	var sbdw1 structBadDequeueWait1
+	err = Make( &sbdw1, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueWait returning non-bool second value" )
+	}
+	var sbdw2 structBadDequeueWait2
+	err = Make( &sbdw2, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueWait returning wrong type" )
+	}
+	var sbdc1 structBadDequeueCtx1
+	err = Make( &sbdc1, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueCtx not taking a context" )
+	}
+	var sbdc2 structBadDequeueCtx2
+	err = Make( &sbdc2, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueCtx not returning an error" )
+	}
+	var sbdt1 structBadDequeueTimeout1
+	err = Make( &sbdt1, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueTimeout not taking a duration" )
+	}
+	var sbdt2 structBadDequeueTimeout2
+	err = Make( &sbdt2, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueTimeout returning one value" )
+	}
+	var sbl structBadLen
+	err = Make( &sbl, config )
+	if err == nil {
+		t.Error( "Make succeeded despite len returning value not of type int" )
+	}
+	var sbif structBadIsFull
+	err = Make( &sbif, config )
+	if err == nil {
+		t.Error( "Make succeeded despite isFull returning value not of type bool" )
+	}
+	var sbeb structBadEnqueueBatch
+	err = Make( &sbeb, config )
+	if err == nil {
+		t.Error( "Make succeeded despite enqueueBatch not taking a slice" )
+	}
+	var sbdb structBadDequeueBatch
+	err = Make( &sbdb, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeueBatch not returning a count" )
+	}
+	var sbtm structBatchTypeMismatch
+	err = Make( &sbtm, config )
+	if err == nil {
+		t.Error( "Make succeeded despite element type mismatch between enqueue and enqueueBatch" )
+	}
+	var sbdr structBadDrain
+	err = Make( &sbdr, config )
+	if err == nil {
+		t.Error( "Make succeeded despite drain not returning a slice" )
+	}
+	var sbcl structBadClear
+	err = Make( &sbcl, config )
+	if err == nil {
+		t.Error( "Make succeeded despite clear returning a value" )
+	}
+	var sbte structBadTryEnqueue
+	err = Make( &sbte, config )
+	if err == nil {
+		t.Error( "Make succeeded despite tryEnqueue not returning bool" )
+	}
+	var stio structTryEnqueueOnly
+	err = Make( &stio, config )
+	if err != nil {
+		t.Errorf( "Make failed with tryEnqueue in place of enqueue: %s", err )
+	}
+	var sbew structBadEnqueueWait
+	err = Make( &sbew, config )
+	if err == nil {
+		t.Error( "Make succeeded despite enqueueWait returning a value" )
+	}
+	var sbec structBadEnqueueCtx
+	err = Make( &sbec, config )
+	if err == nil {
+		t.Error( "Make succeeded despite enqueueCtx taking the context second" )
+	}
+	var sbwe structBadWaitEmpty
+	err = Make( &sbwe, config )
+	if err == nil {
+		t.Error( "Make succeeded despite waitEmpty not taking a context" )
+	}
+	var sbdn structBadDone
+	err = Make( &sbdn, config )
+	if err == nil {
+		t.Error( "Make succeeded despite done taking an argument" )
+	}
+	var sbr structBadReady
+	err = Make( &sbr, config )
+	if err == nil {
+		t.Error( "Make succeeded despite ready returning a bidirectional channel" )
+	}
+	var sba structBadAll
+	err = Make( &sba, config )
+	if err == nil {
+		t.Error( "Make succeeded despite yield function of all not returning bool" )
+	}
+	var sbc structBadConsume
+	err = Make( &sbc, config )
+	if err == nil {
+		t.Error( "Make succeeded despite element type mismatch between enqueue and consume" )
+	}
+	var sbse structBadSeqEnqueue
+	err = Make( &sbse, config )
+	if err == nil {
+		t.Error( "Make succeeded despite enqueue returning int" )
+	}
+	var sbsd structBadSeqDequeue
+	err = Make( &sbsd, config )
+	if err == nil {
+		t.Error( "Make succeeded despite dequeue returning an int sequence number" )
+	}
+	sbless := structBadLess{
+		Less: func( a, b int ) int {
+			return a - b
+		},
+	}
+	err = Make( &sbless, config )
+	if err == nil {
+		t.Error( "Make succeeded despite less not returning bool" )
+	}
+	sltm := structLessTypeMismatch{
+		Less: func( a, b float64 ) bool {
+			return a < b
+		},
+	}
+	err = Make( &sltm, config )
+	if err == nil {
+		t.Error( "Make succeeded despite element type mismatch between enqueue and less" )
+	}
 	var stm1 structTypeMismatch1
 	err = Make( &stm1, config )
 	if err == nil {
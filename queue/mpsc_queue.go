@@ -0,0 +1,203 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// mpscQueue is a lock-free queue for multiple writers and a single reader.
+// It is an intrusive linked list in the style of Dmitry Vyukov's
+// MPSC node-based queue:
+// a writer appends a node with a single atomic swap on tail
+// and then links the previous tail to the new node.
+// The reader owns head, which always points to a dummy node,
+// and never blocks writers.
+// While a writer is between the swap and the link,
+// its element and all elements enqueued after it
+// are not yet visible to the reader.
+type mpscQueue struct {
+	// tail points to the most recently enqueued node (*listNode).
+	// It must only be accessed atomically.
+	tail unsafe.Pointer
+	// enqueued counts the enqueued elements.
+	// It must only be accessed atomically.
+	enqueued uint64
+	_ [cacheLineSize]byte
+	head *listNode
+	// dequeued counts the dequeued elements.
+	// It must only be accessed atomically.
+	dequeued uint64
+}
+
+func newMpscQueue() *mpscQueue {
+	dummy := &listNode{}
+	return &mpscQueue{
+		tail: unsafe.Pointer( dummy ),
+		head: dummy,
+	}
+}
+
+func ( q *mpscQueue ) enqueue( x interface{} ) {
+	node := &listNode{
+		value: x,
+	}
+	atomic.AddUint64( &q.enqueued, 1 )
+	prev := ( *listNode )( atomic.SwapPointer( &q.tail, unsafe.Pointer( node ) ) )
+	atomic.StorePointer( &prev.next, unsafe.Pointer( node ) )
+}
+
+func ( q *mpscQueue ) dequeue() ( x interface{}, ok bool ) {
+	next := ( *listNode )( atomic.LoadPointer( &q.head.next ) )
+	if next == nil {
+		ok = false
+		return
+	}
+	x = next.value
+	next.value = nil
+	ok = true
+	q.head = next
+	atomic.AddUint64( &q.dequeued, 1 )
+
+	return
+}
+
+// enqueueBatch appends all elements with a single atomic swap.
+func ( q *mpscQueue ) enqueueBatch( xs []interface{} ) {
+	if len( xs ) == 0 {
+		return
+	}
+	first, last := newNodeChain( xs )
+	atomic.AddUint64( &q.enqueued, uint64( len( xs ) ) )
+	prev := ( *listNode )( atomic.SwapPointer( &q.tail, unsafe.Pointer( last ) ) )
+	atomic.StorePointer( &prev.next, unsafe.Pointer( first ) )
+}
+
+func ( q *mpscQueue ) dequeueBatch( dst []interface{} ) int {
+	n := 0
+	for n < len( dst ) {
+		next := ( *listNode )( atomic.LoadPointer( &q.head.next ) )
+		if next == nil {
+			break
+		}
+		dst[n] = next.value
+		next.value = nil
+		q.head = next
+		n++
+	}
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+	return n
+}
+
+// drain removes the elements up to the last one
+// visible at the time drain finishes.
+func ( q *mpscQueue ) drain() []interface{} {
+	last, xs, n := drainNodes( q.head, true )
+	q.head = last
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+	return xs
+}
+
+func ( q *mpscQueue ) clear() int {
+	last, _, n := drainNodes( q.head, false )
+	q.head = last
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+	return n
+}
+
+// count returns the number of elements in the queue.
+// The dequeue counter is read first,
+// and the enqueue counter is always incremented before an element
+// becomes visible to readers,
+// so the result is never negative.
+func ( q *mpscQueue ) count() int {
+	dequeued := atomic.LoadUint64( &q.dequeued )
+	enqueued := atomic.LoadUint64( &q.enqueued )
+
+	return int( enqueued - dequeued )
+}
+
+func ( q *mpscQueue ) capacity() int {
+	return q.count()
+}
+
+func ( q *mpscQueue ) peek() ( x interface{}, ok bool ) {
+	next := ( *listNode )( atomic.LoadPointer( &q.head.next ) )
+	if next == nil {
+		ok = false
+		return
+	}
+
+	return next.value, true
+}
+
+// snapshot contains the elements up to the last one
+// visible at the time snapshot finishes.
+// Only the reader removes elements,
+// so this is the content of the queue at that time.
+func ( q *mpscQueue ) snapshot() []interface{} {
+	return snapshotNodes( q.head )
+}
+
+// mpscQueueFactory implements factory for mpscQueue
+type mpscQueueFactory struct {
+	mq *mpscQueue
+}
+
+func ( mqf *mpscQueueFactory ) prepare() {
+	mqf.mq = newMpscQueue()
+}
+
+func ( mqf *mpscQueueFactory ) commit() {
+	// empty
+}
+
+func ( mqf *mpscQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( mqf.mq, methodType )
+}
+
+func ( mqf *mpscQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( mqf.mq, methodType )
+}
+
+func ( mqf *mpscQueueFactory ) queue() interfaceQueue {
+	return mqf.mq
+}
+
+func ( mqf *mpscQueueFactory ) reset() {
+	mqf.mq = nil
+}
+
+// newMpscQueueFactory creates a factory for lock-free queues
+// with multiple writers and a single reader.
+// Since the queue is a linked list, there is no initial capacity.
+func newMpscQueueFactory() factory {
+	return &mpscQueueFactory{
+		mq: nil,
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// msQueue is the non-blocking concurrent queue by Michael and Scott.
+// Both head and tail are updated with compare-and-swap,
+// so any number of readers and writers may access the queue concurrently.
+// The node head points to is a dummy node.
+// Since it may still be read by concurrent readers,
+// the value of the dummy node is not cleared,
+// i. e., the queue keeps a reference to the most recently dequeued element
+// until the next successful dequeue.
+type msQueue struct {
+	// head points to the dummy node (*listNode).
+	// It must only be accessed atomically.
+	head unsafe.Pointer
+	// dequeued counts the dequeued elements.
+	// It must only be accessed atomically.
+	dequeued uint64
+	_ [cacheLineSize]byte
+	// tail points to the last or the second to last node (*listNode).
+	// It must only be accessed atomically.
+	tail unsafe.Pointer
+	// enqueued counts the enqueued elements.
+	// It must only be accessed atomically.
+	enqueued uint64
+}
+
+func newMsQueue() *msQueue {
+	dummy := unsafe.Pointer( &listNode{} )
+	return &msQueue{
+		head: dummy,
+		tail: dummy,
+	}
+}
+
+func ( q *msQueue ) enqueue( x interface{} ) {
+	node := unsafe.Pointer( &listNode{
+		value: x,
+	} )
+	atomic.AddUint64( &q.enqueued, 1 )
+	for {
+		tail := atomic.LoadPointer( &q.tail )
+		next := atomic.LoadPointer( &( *listNode )( tail ).next )
+		if tail != atomic.LoadPointer( &q.tail ) {
+			continue
+		}
+		if next != nil {
+			// tail is lagging behind, help advance it
+			atomic.CompareAndSwapPointer( &q.tail, tail, next )
+			continue
+		}
+		if atomic.CompareAndSwapPointer( &( *listNode )( tail ).next, nil, node ) {
+			atomic.CompareAndSwapPointer( &q.tail, tail, node )
+			return
+		}
+	}
+}
+
+func ( q *msQueue ) dequeue() ( x interface{}, ok bool ) {
+	for {
+		head := atomic.LoadPointer( &q.head )
+		tail := atomic.LoadPointer( &q.tail )
+		next := atomic.LoadPointer( &( *listNode )( head ).next )
+		if head != atomic.LoadPointer( &q.head ) {
+			continue
+		}
+		if next == nil {
+			ok = false
+			return
+		}
+		if head == tail {
+			// tail is lagging behind, help advance it
+			atomic.CompareAndSwapPointer( &q.tail, tail, next )
+			continue
+		}
+		x = ( *listNode )( next ).value
+		if atomic.CompareAndSwapPointer( &q.head, head, next ) {
+			atomic.AddUint64( &q.dequeued, 1 )
+			ok = true
+			return
+		}
+	}
+}
+
+// drain moves head to the last node with a single atomic operation.
+// As with dequeue, the new dummy node keeps its value.
+func ( q *msQueue ) drain() []interface{} {
+	xs, _ := q.drainNodes( true )
+
+	return xs
+}
+
+func ( q *msQueue ) clear() int {
+	_, n := q.drainNodes( false )
+
+	return n
+}
+
+// drainNodes implements drain.
+// It returns the number n of removed nodes.
+// The values of the removed nodes are returned only if keep is true.
+// Node values and links are never modified after a node has been linked,
+// so the removed nodes can be visited after head has been moved.
+func ( q *msQueue ) drainNodes( keep bool ) ( xs []interface{}, n int ) {
+	for {
+		head := atomic.LoadPointer( &q.head )
+		tail := atomic.LoadPointer( &q.tail )
+		next := atomic.LoadPointer( &( *listNode )( head ).next )
+		if head != atomic.LoadPointer( &q.head ) {
+			continue
+		}
+		if next == nil {
+			return
+		}
+		if last := atomic.LoadPointer( &( *listNode )( tail ).next ); last != nil {
+			// tail is lagging behind, help advance it
+			atomic.CompareAndSwapPointer( &q.tail, tail, last )
+			continue
+		}
+		if head == tail {
+			continue
+		}
+		if !atomic.CompareAndSwapPointer( &q.head, head, tail ) {
+			continue
+		}
+		for node := next; ; node = atomic.LoadPointer( &( *listNode )( node ).next ) {
+			if keep {
+				xs = append( xs, ( *listNode )( node ).value )
+			}
+			n++
+			if node == tail {
+				break
+			}
+		}
+		atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+		return
+	}
+}
+
+// count works like mpscQueue.count.
+func ( q *msQueue ) count() int {
+	dequeued := atomic.LoadUint64( &q.dequeued )
+	enqueued := atomic.LoadUint64( &q.enqueued )
+
+	return int( enqueued - dequeued )
+}
+
+func ( q *msQueue ) capacity() int {
+	return q.count()
+}
+
+// peek returns the value of the node after the dummy node.
+// Node values are never modified after a node has been enqueued,
+// so the value can be read safely even if the node is dequeued
+// concurrently.
+func ( q *msQueue ) peek() ( x interface{}, ok bool ) {
+	for {
+		head := atomic.LoadPointer( &q.head )
+		next := atomic.LoadPointer( &( *listNode )( head ).next )
+		if head != atomic.LoadPointer( &q.head ) {
+			continue
+		}
+		if next == nil {
+			ok = false
+			return
+		}
+
+		return ( *listNode )( next ).value, true
+	}
+}
+
+// snapshot walks the list from head to the last node.
+// If head has not moved by the time the last node has been reached,
+// no element has been dequeued in the meantime,
+// so the values visited are the content of the queue at that time.
+// Otherwise, snapshot starts over.
+func ( q *msQueue ) snapshot() []interface{} {
+	for {
+		head := atomic.LoadPointer( &q.head )
+		xs := snapshotNodes( ( *listNode )( head ) )
+		if head == atomic.LoadPointer( &q.head ) {
+			return xs
+		}
+	}
+}
+
+// msQueueFactory implements factory for msQueue
+type msQueueFactory struct {
+	mq *msQueue
+}
+
+func ( mqf *msQueueFactory ) prepare() {
+	mqf.mq = newMsQueue()
+}
+
+func ( mqf *msQueueFactory ) commit() {
+	// empty
+}
+
+func ( mqf *msQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( mqf.mq, methodType )
+}
+
+func ( mqf *msQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( mqf.mq, methodType )
+}
+
+func ( mqf *msQueueFactory ) queue() interfaceQueue {
+	return mqf.mq
+}
+
+func ( mqf *msQueueFactory ) reset() {
+	mqf.mq = nil
+}
+
+// newMsQueueFactory creates a factory for lock-free queues
+// with multiple readers and multiple writers.
+// Since the queue is a linked list, there is no initial capacity.
+func newMsQueueFactory() factory {
+	return &msQueueFactory{
+		mq: nil,
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMsQueue( t *testing.T ) {
+	testMpmcQueue( t, newMsQueueFactory() )
+}
+
+// testMpmcQueue checks a FIFO queue made by f
+// which supports multiple readers and writers.
+func testMpmcQueue( t *testing.T, f factory ) {
+	f.prepare()
+	var enqueue func( int )
+	var dequeue func() ( int, bool )
+	enqueue = f.makeEnqueue( reflect.TypeOf( enqueue ) ).Interface().( func( int ) )
+	dequeue = f.makeDequeue( reflect.TypeOf( dequeue ) ).Interface().( func() ( int, bool ) )
+	f.commit()
+	f.reset()
+	// Empty dequeue check
+	x, ok := dequeue()
+	if ok {
+		t.Error( "Dequeue succeeds on empty queue" )
+	}
+	if x != 0 {
+		t.Errorf( "Failed dequeue does not return zero value: %d", x )
+	}
+	// Sequential queue order check
+	for i := 0; i < 100; i++ {
+		enqueue( i )
+	}
+	for i := 0; i < 100; i++ {
+		x, ok = dequeue()
+		if !ok {
+			t.Error( "Dequeue fails on non-empty queue" )
+		}
+		if x != i {
+			t.Errorf( "Dequeue returned wrong value: %d instead of %d", x, i )
+		}
+	}
+	// Parallel queue check.
+	// Each writer enqueues its own ascending sequence.
+	// Each reader must see each writer's elements in order,
+	// and all elements must be dequeued exactly once.
+	const writers = 4
+	const readers = 4
+	const iterations = 10000
+	var seen [writers * iterations]int32
+	var received int32
+	var wg sync.WaitGroup
+	writer := func( id int ) {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			enqueue( id * iterations + i )
+		}
+	}
+	reader := func() {
+		defer wg.Done()
+		var previous [writers]int
+		for id := range previous {
+			previous[id] = -1
+		}
+		for atomic.LoadInt32( &received ) < writers * iterations {
+			x, ok := dequeue()
+			if !ok {
+				runtime.Gosched()
+				continue
+			}
+			id := x / iterations
+			if x <= previous[id] {
+				t.Errorf( "Out of order dequeue: %d after %d", x, previous[id] )
+			}
+			previous[id] = x
+			atomic.AddInt32( &seen[x], 1 )
+			atomic.AddInt32( &received, 1 )
+		}
+	}
+	wg.Add( writers + readers )
+	for id := 0; id < writers; id++ {
+		go writer( id )
+	}
+	for id := 0; id < readers; id++ {
+		go reader()
+	}
+	wg.Wait()
+	for x := range seen {
+		if seen[x] != 1 {
+			t.Errorf( "Element %d dequeued %d times", x, seen[x] )
+		}
+	}
+	if x, ok := dequeue(); ok {
+		t.Errorf( "Spurious successful dequeue: %d", x )
+	}
+}
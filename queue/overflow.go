@@ -0,0 +1,358 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// signal lets goroutines wait until another goroutine announces
+// that a condition may have changed.
+// A waiter first calls prepare, then rechecks the condition,
+// and only then waits on the returned channel.
+// This way, a notification between the check and the wait cannot get lost.
+type signal struct {
+	mx sync.Mutex
+	// waiters is the number of goroutines which called prepare since
+	// the last notification.
+	// It must only be accessed atomically.
+	waiters int32
+	ch chan struct{}
+}
+
+// waitHook, if not nil, is called whenever a goroutine registers
+// as a waiter with a signal or a waiterList.
+// Tests set it to find out when a goroutine is about to block.
+var waitHook func()
+
+// prepare registers the calling goroutine as a waiter.
+// The returned channel is closed by the next call to notify.
+func ( s *signal ) prepare() <-chan struct{} {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.ch == nil {
+		s.ch = make( chan struct{} )
+	}
+	atomic.AddInt32( &s.waiters, 1 )
+	if waitHook != nil {
+		waitHook()
+	}
+
+	return s.ch
+}
+
+// notify wakes up all registered waiters.
+// If there are no waiters, notify does not block.
+func ( s *signal ) notify() {
+	if atomic.LoadInt32( &s.waiters ) == 0 {
+		return
+	}
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.ch != nil {
+		close( s.ch )
+		s.ch = nil
+	}
+	atomic.StoreInt32( &s.waiters, 0 )
+}
+
+// waiterList lets goroutines wait in FIFO order.
+// Only the goroutine at the head of the list is woken up by notify.
+// When it leaves the list, the next goroutine becomes the head
+// and is woken up in turn.
+type waiterList struct {
+	mx sync.Mutex
+	// length is the number of waiting goroutines.
+	// It is only modified with mx held,
+	// and must only be accessed atomically.
+	length int32
+	waiters []chan struct{}
+}
+
+// join appends the calling goroutine to the list.
+// The returned channel receives a value whenever the goroutine is woken up.
+// A goroutine which has joined the list must eventually leave it.
+func ( l *waiterList ) join() chan struct{} {
+	ch := make( chan struct{}, 1 )
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.waiters = append( l.waiters, ch )
+	atomic.StoreInt32( &l.length, int32( len( l.waiters ) ) )
+	if waitHook != nil {
+		waitHook()
+	}
+
+	return ch
+}
+
+// isHead reports whether the goroutine with channel ch is at the head
+// of the list.
+func ( l *waiterList ) isHead( ch chan struct{} ) bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return ( len( l.waiters ) != 0 ) && ( l.waiters[0] == ch )
+}
+
+// leave removes the goroutine with channel ch from the list.
+// If it was at the head, the new head is woken up.
+func ( l *waiterList ) leave( ch chan struct{} ) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	for i, waiter := range l.waiters {
+		if waiter != ch {
+			continue
+		}
+		copy( l.waiters[i:], l.waiters[i + 1:] )
+		l.waiters[len( l.waiters ) - 1] = nil
+		l.waiters = l.waiters[:len( l.waiters ) - 1]
+		atomic.StoreInt32( &l.length, int32( len( l.waiters ) ) )
+		if i == 0 {
+			l.wakeHead()
+		}
+		return
+	}
+}
+
+// notify wakes up the goroutine at the head of the list.
+// If the list is empty, notify does not block.
+func ( l *waiterList ) notify() {
+	if atomic.LoadInt32( &l.length ) == 0 {
+		return
+	}
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.wakeHead()
+}
+
+// wakeHead wakes up the goroutine at the head of the list.
+// The caller must hold mx.
+func ( l *waiterList ) wakeHead() {
+	if len( l.waiters ) == 0 {
+		return
+	}
+	select {
+	case l.waiters[0] <- struct{}{}:
+	default:
+		// already awake
+	}
+}
+
+// boundedInterfaceQueue is the internal interface of queues
+// with a capacity limit.
+type boundedInterfaceQueue interface {
+	interfaceQueue
+
+	// tryEnqueue enqueues x unless the queue is full.
+	// The return value indicates whether x has been enqueued.
+	tryEnqueue( x interface{} ) bool
+}
+
+// boundedBatchQueue is the internal interface of bounded queues
+// which can enqueue several elements at once.
+type boundedBatchQueue interface {
+	// tryEnqueueBatch enqueues the leading elements of xs
+	// as long as the queue is not full,
+	// and returns the number of enqueued elements.
+	tryEnqueueBatch( xs []interface{} ) int
+}
+
+// overflowQueue applies an overflow policy to a bounded queue.
+type overflowQueue struct {
+	queue boundedInterfaceQueue
+	policy OverflowPolicy
+	// discarded counts the elements discarded due to the policy.
+	// It must only be accessed atomically.
+	discarded uint64
+	// notFull holds the writers waiting for the queue to become non-full.
+	// It is notified after each successful dequeue.
+	notFull waiterList
+	// writable is notified along with notFull.
+	// It serves the channels returned by the notFull function
+	// of the queue structure (see makeNotFull).
+	writable signal
+	// done is closed when the queue is closed.
+	// Blocked writers then give up with ErrClosed.
+	// It is nil if the queue cannot be closed.
+	done <-chan struct{}
+}
+
+func newOverflowQueue( queue boundedInterfaceQueue, policy OverflowPolicy ) *overflowQueue {
+	return &overflowQueue{
+		queue: queue,
+		policy: policy,
+	}
+}
+
+func ( q *overflowQueue ) enqueue( x interface{} ) {
+	if q.policy == OverflowBlock {
+		if err := q.enqueueCtx( context.Background(), x ); err != nil {
+			panic( err )
+		}
+		return
+	}
+	for !q.queue.tryEnqueue( x ) {
+		switch q.policy {
+		case OverflowReject:
+			atomic.AddUint64( &q.discarded, 1 )
+			panic( ErrFull )
+		case OverflowDropOldest:
+			if q.dropOldest() {
+				atomic.AddUint64( &q.discarded, 1 )
+			}
+		case OverflowDropNewest:
+			atomic.AddUint64( &q.discarded, 1 )
+			return
+		}
+	}
+}
+
+// enqueueCtx enqueues x regardless of the overflow policy,
+// waiting while the queue is full,
+// until the queue is closed or ctx is done.
+// In the latter cases, ErrClosed or ctx.Err() is returned, respectively.
+// Waiting writers are served in FIFO order:
+// only the writer at the head of notFull tries to enqueue,
+// and a writer only skips the list if nobody is waiting.
+func ( q *overflowQueue ) enqueueCtx( ctx context.Context, x interface{} ) error {
+	if ( atomic.LoadInt32( &q.notFull.length ) == 0 ) && q.queue.tryEnqueue( x ) {
+		return nil
+	}
+	ch := q.notFull.join()
+	defer q.notFull.leave( ch )
+	for {
+		if q.notFull.isHead( ch ) && q.queue.tryEnqueue( x ) {
+			return nil
+		}
+		select {
+		case <-ch:
+		case <-q.done:
+			return ErrClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// tryEnqueue applies the overflow policy without blocking or panicking.
+// With OverflowDropOldest, x is always enqueued.
+// With OverflowBlock, x is not enqueued if the queue is full
+// or writers are waiting,
+// so that it does not take a slot freed for the writer at the head of notFull.
+func ( q *overflowQueue ) tryEnqueue( x interface{} ) bool {
+	if ( q.policy == OverflowBlock ) && ( atomic.LoadInt32( &q.notFull.length ) != 0 ) {
+		return false
+	}
+	if q.queue.tryEnqueue( x ) {
+		return true
+	}
+	switch q.policy {
+	case OverflowBlock:
+		return false
+	case OverflowDropOldest:
+		q.enqueue( x )
+		return true
+	default:
+		atomic.AddUint64( &q.discarded, 1 )
+		return false
+	}
+}
+
+// enqueueBatch enqueues as many elements of xs as fit at once,
+// unless writers are waiting with OverflowBlock.
+// The overflow policy is then applied to the remaining elements
+// one at a time (see enqueue).
+func ( q *overflowQueue ) enqueueBatch( xs []interface{} ) {
+	if ( q.policy != OverflowBlock ) || ( atomic.LoadInt32( &q.notFull.length ) == 0 ) {
+		xs = xs[tryEnqueueBatch( q.queue, xs ):]
+	}
+	for _, x := range xs {
+		q.enqueue( x )
+	}
+}
+
+// dropOldest dequeues the oldest element to discard it.
+// The return value indicates whether there was an element to discard.
+func ( q *overflowQueue ) dropOldest() bool {
+	if fq, ok := q.queue.( *flushQueue ); ok {
+		return fq.drop()
+	}
+	_, ok := q.queue.dequeue()
+
+	return ok
+}
+
+func ( q *overflowQueue ) dequeue() ( x interface{}, ok bool ) {
+	x, ok = q.queue.dequeue()
+	if ok {
+		q.wakeWriters()
+	}
+
+	return
+}
+
+// dequeueBatch wakes up blocked writers once for the whole batch.
+func ( q *overflowQueue ) dequeueBatch( dst []interface{} ) int {
+	n := dequeueBatch( q.queue, dst )
+	if n != 0 {
+		q.wakeWriters()
+	}
+
+	return n
+}
+
+// drain drains the bounded queue, which must support it
+// (see asDrainQueue),
+// and then wakes up blocked writers.
+func ( q *overflowQueue ) drain() []interface{} {
+	xs := asDrainQueue( q.queue ).drain()
+	if len( xs ) != 0 {
+		q.wakeWriters()
+	}
+
+	return xs
+}
+
+// clear works like drain.
+func ( q *overflowQueue ) clear() int {
+	n := asDrainQueue( q.queue ).clear()
+	q.wakeWriters()
+
+	return n
+}
+
+// wakeWriters announces that the queue may no longer be full.
+func ( q *overflowQueue ) wakeWriters() {
+	q.notFull.notify()
+	q.writable.notify()
+}
+
+func ( q *overflowQueue ) unwrap() interfaceQueue {
+	return q.queue
+}
+
+// discardedCount returns the number of elements discarded so far.
+func ( q *overflowQueue ) discardedCount() uint64 {
+	return atomic.LoadUint64( &q.discarded )
+}
@@ -0,0 +1,435 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// overflowTestQueue is used to test overflow policies.
+type overflowTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Discarded func() uint64 `queue:"discarded"`
+}
+
+func TestOverflowBlockLockFree( t *testing.T ) {
+	var q overflowTestQueue
+	if err := Make( &q, DefaultConfig().LockFree().Bounded( 3 ).Overflow( OverflowBlock ) ); err == nil {
+		t.Error( "Make succeeded with lock-free blocking queue" )
+	}
+	if err := Make( &q, DefaultConfig().SplitLock().Bounded( 3 ).Overflow( OverflowBlock ) ); err == nil {
+		t.Error( "Make succeeded with split-lock blocking queue" )
+	}
+	if err := Make( &q, DefaultConfig().LockFree().Bounded( 3 ).Overflow( OverflowReject ) ); err != nil {
+		t.Errorf( "Make failed with lock-free rejecting queue: %s", err )
+	}
+}
+
+func TestOverflowReject( t *testing.T ) {
+	const limit = 3
+	for _, config := range withOverflow( filterConfigs( allConfigs( limit ), boundedConfig, unpinnedConfig, nonBlockingConfig ), OverflowReject ) {
+		var q overflowTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		for i := 0; i < limit; i++ {
+			q.Enqueue( i )
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != ErrFull {
+					t.Errorf( "Enqueue on full queue did not panic with ErrFull: %v", r )
+				}
+			}()
+			q.Enqueue( limit )
+		}()
+		if q.Discarded() != 1 {
+			t.Errorf( "Discarded count %d after rejection, expected 1", q.Discarded() )
+		}
+		for i := 0; i < limit; i++ {
+			if x, _ := q.Dequeue(); x != i {
+				t.Errorf( "Dequeue returned wrong value: %d instead of %d", x, i )
+			}
+		}
+	}
+}
+
+func TestOverflowDropOldest( t *testing.T ) {
+	const limit = 3
+	for _, config := range withOverflow( filterConfigs( allConfigs( limit ), boundedConfig, unpinnedConfig, nonBlockingConfig ), OverflowDropOldest ) {
+		var q overflowTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		for i := 0; i < 10; i++ {
+			q.Enqueue( i )
+		}
+		if q.Discarded() != 10 - limit {
+			t.Errorf( "Discarded count %d, expected %d", q.Discarded(), 10 - limit )
+		}
+		for i := 10 - limit; i < 10; i++ {
+			if x, _ := q.Dequeue(); x != i {
+				t.Errorf( "Dequeue returned wrong value: %d instead of %d", x, i )
+			}
+		}
+		if x, ok := q.Dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue: %d", x )
+		}
+	}
+}
+
+func TestOverflowDropNewest( t *testing.T ) {
+	const limit = 3
+	for _, config := range withOverflow( filterConfigs( allConfigs( limit ), boundedConfig, unpinnedConfig, nonBlockingConfig ), OverflowDropNewest ) {
+		var q overflowTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		for i := 0; i < 10; i++ {
+			q.Enqueue( i )
+		}
+		if q.Discarded() != 10 - limit {
+			t.Errorf( "Discarded count %d, expected %d", q.Discarded(), 10 - limit )
+		}
+		for i := 0; i < limit; i++ {
+			if x, _ := q.Dequeue(); x != i {
+				t.Errorf( "Dequeue returned wrong value: %d instead of %d", x, i )
+			}
+		}
+		if x, ok := q.Dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue: %d", x )
+		}
+	}
+}
+
+func TestOverflowBlock( t *testing.T ) {
+	const limit = 3
+	const iterations = 1000
+	var q overflowTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( limit ).Overflow( OverflowBlock ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	var wg sync.WaitGroup
+	wg.Add( 1 )
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			q.Enqueue( i )
+		}
+	}()
+	for i := 0; i < iterations; {
+		x, ok := q.Dequeue()
+		if !ok {
+			time.Sleep( time.Microsecond )
+			continue
+		}
+		if x != i {
+			t.Fatalf( "Dequeue returned wrong value: %d instead of %d", x, i )
+		}
+		i++
+	}
+	wg.Wait()
+	if q.Discarded() != 0 {
+		t.Errorf( "Blocking queue discarded %d elements", q.Discarded() )
+	}
+}
+
+func TestDiscardedUnbounded( t *testing.T ) {
+	var q overflowTestQueue
+	if err := Make( &q, nil ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	for i := 0; i < 10; i++ {
+		q.Enqueue( i )
+	}
+	if q.Discarded() != 0 {
+		t.Errorf( "Unbounded queue discarded %d elements", q.Discarded() )
+	}
+}
+
+// tryEnqueueTestQueue is used to test non-blocking enqueueing.
+type tryEnqueueTestQueue struct {
+	TryEnqueue func( int ) bool `queue:"tryEnqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Discarded func() uint64 `queue:"discarded"`
+}
+
+func TestTryEnqueue( t *testing.T ) {
+	const limit = 3
+	policies := []OverflowPolicy{ OverflowReject, OverflowBlock, OverflowDropOldest, OverflowDropNewest }
+	for _, policy := range policies {
+		configs := withOverflow( filterConfigs( allConfigs( limit ), boundedConfig, unpinnedConfig, nonBlockingConfig ), policy )
+		for _, config := range configs {
+			var q tryEnqueueTestQueue
+			if err := Make( &q, config ); err != nil {
+				t.Fatalf( "Make failed with policy %d: %s", policy, err )
+			}
+			for i := 0; i < limit; i++ {
+				if !q.TryEnqueue( i ) {
+					t.Errorf( "TryEnqueue fails on non-full queue with policy %d", policy )
+				}
+			}
+			accepted := q.TryEnqueue( limit )
+			if accepted != ( policy == OverflowDropOldest ) {
+				t.Errorf( "TryEnqueue on full queue returns %v with policy %d", accepted, policy )
+			}
+			first := 0
+			if accepted {
+				first = 1
+			}
+			for i := first; i < first + limit; i++ {
+				if x, _ := q.Dequeue(); x != i {
+					t.Errorf( "Dequeue returned wrong value with policy %d: %d instead of %d", policy, x, i )
+				}
+			}
+			if ( policy == OverflowBlock ) && ( q.Discarded() != 0 ) {
+				t.Errorf( "Blocking queue discarded %d elements", q.Discarded() )
+			}
+		}
+	}
+	var q tryEnqueueTestQueue
+	if err := Make( &q, nil ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	for i := 0; i < 1000; i++ {
+		if !q.TryEnqueue( i ) {
+			t.Fatal( "TryEnqueue fails on unbounded queue" )
+		}
+	}
+}
+
+// enqueueWaitTestQueue is used to test waiting writers.
+type enqueueWaitTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	EnqueueWait func( int ) `queue:"enqueueWait"`
+	EnqueueCtx func( context.Context, int ) error `queue:"enqueueCtx"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Close func() `queue:"close"`
+}
+
+func TestEnqueueWait( t *testing.T ) {
+	const iterations = 1000
+	for _, policy := range []OverflowPolicy{ OverflowReject, OverflowBlock, OverflowDropOldest } {
+		var q enqueueWaitTestQueue
+		if err := Make( &q, DefaultConfig().Bounded( 2 ).Overflow( policy ) ); err != nil {
+			t.Fatalf( "Make failed with policy %d: %s", policy, err )
+		}
+		go func() {
+			for i := 0; i < iterations; i++ {
+				q.EnqueueWait( i )
+			}
+		}()
+		for i := 0; i < iterations; {
+			x, ok := q.Dequeue()
+			if !ok {
+				time.Sleep( time.Microsecond )
+				continue
+			}
+			if x != i {
+				t.Fatalf( "Dequeue returned wrong value with policy %d: %d instead of %d", policy, x, i )
+			}
+			i++
+		}
+	}
+	var q enqueueWaitTestQueue
+	if err := Make( &q, nil ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	for i := 0; i < iterations; i++ {
+		q.EnqueueWait( i )
+	}
+}
+
+func TestEnqueueWaitFair( t *testing.T ) {
+	const writers = 10
+	oq := newOverflowQueue( newLockedQueue( 1, 1, DefaultShrinkRatio ), OverflowReject )
+	oq.queue.( *lockedQueue ).limit = 1
+	oq.enqueue( -1 )
+	for i := 0; i < writers; i++ {
+		go oq.enqueueCtx( context.Background(), i )
+		for atomic.LoadInt32( &oq.notFull.length ) != int32( i + 1 ) {
+			time.Sleep( time.Microsecond )
+		}
+	}
+	for i := -1; i < writers; {
+		x, ok := oq.dequeue()
+		if !ok {
+			time.Sleep( time.Microsecond )
+			continue
+		}
+		if x != i {
+			t.Fatalf( "Writers not served in FIFO order: %d instead of %d", x, i )
+		}
+		i++
+	}
+}
+
+func TestTryEnqueueWaiters( t *testing.T ) {
+	oq := newOverflowQueue( newLockedQueue( 1, 1, DefaultShrinkRatio ), OverflowBlock )
+	oq.queue.( *lockedQueue ).limit = 1
+	oq.enqueue( 0 )
+	done := make( chan error )
+	go func() {
+		done <- oq.enqueueCtx( context.Background(), 1 )
+	}()
+	for atomic.LoadInt32( &oq.notFull.length ) != 1 {
+		time.Sleep( time.Microsecond )
+	}
+	if x, ok := oq.dequeue(); !ok || ( x != 0 ) {
+		t.Fatalf( "Dequeue returned %v, %t instead of 0, true", x, ok )
+	}
+	// The freed slot belongs to the waiting writer.
+	if oq.tryEnqueue( 2 ) {
+		t.Error( "Non-blocking enqueue overtook waiting writer" )
+	}
+	if err := <-done; err != nil {
+		t.Fatalf( "Waiting writer failed: %s", err )
+	}
+	if x, ok := oq.dequeue(); !ok || ( x != 1 ) {
+		t.Errorf( "Dequeue returned %v, %t instead of 1, true", x, ok )
+	}
+	if !oq.tryEnqueue( 3 ) {
+		t.Error( "Non-blocking enqueue failed without waiting writers" )
+	}
+}
+
+func TestEnqueueCtx( t *testing.T ) {
+	var q enqueueWaitTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 1 ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	if err := q.EnqueueCtx( context.Background(), 1 ); err != nil {
+		t.Errorf( "EnqueueCtx into empty queue failed: %s", err )
+	}
+	ctx, cancel := context.WithTimeout( context.Background(), 10 * time.Millisecond )
+	defer cancel()
+	if err := q.EnqueueCtx( ctx, 2 ); err != context.DeadlineExceeded {
+		t.Errorf( "EnqueueCtx into full queue returned %v instead of timing out", err )
+	}
+	// The cancelled writer must not hold up others.
+	done := make( chan error )
+	startWaiting( t, func() {
+		done <- q.EnqueueCtx( context.Background(), 3 )
+	} )
+	if x, ok := q.Dequeue(); !ok || ( x != 1 ) {
+		t.Errorf( "Dequeue returned %d, %v instead of 1", x, ok )
+	}
+	if err := <-done; err != nil {
+		t.Errorf( "Waiting EnqueueCtx failed: %s", err )
+	}
+	startWaiting( t, func() {
+		done <- q.EnqueueCtx( context.Background(), 4 )
+	} )
+	q.Close()
+	if err := <-done; err != ErrClosed {
+		t.Errorf( "EnqueueCtx on closed queue returned %v instead of ErrClosed", err )
+	}
+	if x, ok := q.Dequeue(); !ok || ( x != 3 ) {
+		t.Errorf( "Dequeue returned %d, %v instead of 3", x, ok )
+	}
+}
+
+func TestEnqueueCtxNonConcurrent( t *testing.T ) {
+	var q enqueueWaitTestQueue
+	if err := Make( &q, DefaultConfig().NonConcurrent().Bounded( 1 ).Overflow( OverflowDropOldest ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.EnqueueWait( 1 )
+	if err := q.EnqueueCtx( context.Background(), 2 ); err != ErrFull {
+		t.Errorf( "EnqueueCtx into full non-concurrent queue returned %v instead of ErrFull", err )
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != ErrFull {
+				t.Errorf( "EnqueueWait into full non-concurrent queue recovered %v instead of ErrFull", r )
+			}
+		}()
+		q.EnqueueWait( 3 )
+	}()
+	if x, ok := q.Dequeue(); !ok || ( x != 1 ) {
+		t.Errorf( "Dequeue returned %d, %v instead of 1", x, ok )
+	}
+}
+
+func TestNotFull( t *testing.T ) {
+	var q readyTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 1 ).Overflow( OverflowBlock ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	if !isReady( q.NotFull() ) {
+		t.Error( "Empty queue not ready for writers" )
+	}
+	q.Enqueue( 1 )
+	ch := q.NotFull()
+	if isClosed( ch ) {
+		t.Error( "Full queue ready for writers" )
+	}
+	go q.Dequeue()
+	if !isReady( ch ) {
+		t.Error( "NotFull channel not closed after dequeue" )
+	}
+	q.Enqueue( 2 )
+	ch = q.NotFull()
+	q.Close()
+	if !isReady( ch ) {
+		t.Error( "NotFull channel not closed after close" )
+	}
+	var uq readyTestQueue
+	if err := Make( &uq, nil ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	if !isReady( uq.NotFull() ) {
+		t.Error( "Unbounded queue not ready for writers" )
+	}
+}
+
+func TestNotFullRegistered( t *testing.T ) {
+	constructor := func( params Parameters ) Implementation {
+		return &sliceImplementation{}
+	}
+	if err := Register( "test-notfull", FMultiReader | FMultiWriter | FBounded, constructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	defer unregister( "test-notfull" )
+	// The registered implementation cannot tell writers when it has space.
+	var q readyTestQueue
+	config := DefaultConfig().Bounded( 1 ).Implementation( "test-notfull" )
+	if err := Make( &q, config ); err == nil {
+		t.Error( "Make succeeded for bounded registered implementation with notFull" )
+	}
+	var sq struct{
+		Enqueue func( int ) `queue:"enqueue"`
+		Dequeue func() ( int, bool ) `queue:"dequeue"`
+	}
+	if err := Make( &sq, config ); err != nil {
+		t.Errorf( "Make failed for bounded registered implementation: %s", err )
+	}
+	if err := Make( &q, DefaultConfig().Implementation( "test-notfull" ) ); err != nil {
+		t.Errorf( "Make failed for unbounded registered implementation with notFull: %s", err )
+	}
+}
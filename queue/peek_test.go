@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync"
+	"testing"
+)
+
+// peekTestQueue is used to test peeking.
+type peekTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Peek func() ( int, bool ) `queue:"peek"`
+}
+
+func TestPeek( t *testing.T ) {
+	for _, config := range allConfigs( 8 ) {
+		var q peekTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		if x, ok := q.Peek(); ok || ( x != 0 ) {
+			t.Errorf( "Peek on empty queue returned %d, %t", x, ok )
+		}
+		for i := 1; i <= 3; i++ {
+			q.Enqueue( i )
+		}
+		for i := 1; i <= 3; i++ {
+			for j := 0; j < 2; j++ {
+				if x, ok := q.Peek(); !ok || ( x != i ) {
+					t.Errorf( "Peek returned %d, %t instead of %d, true with flags %x", x, ok, i, config.Flags )
+				}
+			}
+			if x, ok := q.Dequeue(); !ok || ( x != i ) {
+				t.Errorf( "Dequeue returned %d, %t instead of %d, true with flags %x", x, ok, i, config.Flags )
+			}
+		}
+		if _, ok := q.Peek(); ok {
+			t.Errorf( "Peek succeeds on drained queue with flags %x", config.Flags )
+		}
+	}
+}
+
+func TestPeekSelection( t *testing.T ) {
+	var q peekTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 8 ).Implementation( "bounded" ) ); err == nil {
+		t.Error( "Make succeeded with peek on implementation without peek support" )
+	}
+	peekConstructor := func( params Parameters ) Implementation {
+		return &sliceImplementation{}
+	}
+	if err := Register( "test-peek", FMultiReader | FMultiWriter, peekConstructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	defer unregister( "test-peek" )
+	if err := Make( &q, DefaultConfig().Implementation( "test-peek" ) ); err != nil {
+		t.Errorf( "Make failed with peek on registered implementation with peek support: %s", err )
+	}
+	constructor := func( params Parameters ) Implementation {
+		return &sliceNoPeekImplementation{}
+	}
+	if err := Register( "test-nopeek", FMultiReader | FMultiWriter, constructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	defer unregister( "test-nopeek" )
+	if err := Make( &q, DefaultConfig().Implementation( "test-nopeek" ) ); err == nil {
+		t.Error( "Make succeeded with peek on registered implementation without peek support" )
+	}
+}
+
+func TestPeekParallel( t *testing.T ) {
+	// With multiple readers, peeked elements may be gone,
+	// but peeking must neither crash nor return elements never enqueued.
+	const iterations = 10000
+	for _, config := range filterConfigs( allConfigs( 8 ), multiReaderConfig, unboundedConfig ) {
+		var q peekTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		var wg sync.WaitGroup
+		wg.Add( 3 )
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= iterations; i++ {
+				q.Enqueue( i )
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				q.Dequeue()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if x, ok := q.Peek(); ok && ( ( x < 1 ) || ( x > iterations ) ) {
+					t.Errorf( "Peek returned bogus element %d with flags %x", x, config.Flags )
+					return
+				}
+			}
+		}()
+		wg.Wait()
+	}
+}
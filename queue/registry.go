@@ -0,0 +1,431 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// Implementation is the interface a queue implementation must satisfy
+// in order to be registered with Register.
+// Make bridges the typed functions of the client's queue structure
+// to these generic methods.
+type Implementation interface {
+	// Enqueue enqueues element x into the queue.
+	Enqueue( x interface{} )
+
+	// Dequeue attempts to dequeue an element from the queue.
+	// If the queue is empty, ok is false.
+	Dequeue() ( x interface{}, ok bool )
+}
+
+// Peeker is an optional interface for implementations
+// which can return the element at the head of the queue
+// without removing it.
+// Implementations which do not satisfy Peeker
+// are not used for queue structures with a peek function.
+type Peeker interface {
+	// Peek returns the element at the head of the queue.
+	// If the queue is empty, ok is false.
+	Peek() ( x interface{}, ok bool )
+}
+
+// Sizer is an optional interface for implementations
+// which can report their size.
+// Implementations which do not satisfy Sizer
+// are not used for queue structures with len, cap, isEmpty,
+// or isFull functions.
+type Sizer interface {
+	// Len returns the number of elements in the queue.
+	Len() int
+
+	// Cap returns the number of elements the queue can hold
+	// without allocating more storage.
+	Cap() int
+}
+
+// Drainer is an optional interface for implementations
+// which can remove all elements at once.
+// Implementations which do not satisfy Drainer
+// are not used for queue structures with drain or clear functions.
+type Drainer interface {
+	// Drain atomically removes all elements from the queue
+	// and returns them in order.
+	Drain() []interface{}
+
+	// Clear atomically removes all elements from the queue,
+	// releases the storage they occupied,
+	// and returns the number of removed elements.
+	Clear() int
+}
+
+// Snapshotter is an optional interface for implementations
+// which can list their elements without removing them.
+// Implementations which do not satisfy Snapshotter
+// are not used for queue structures with an all function.
+type Snapshotter interface {
+	// Snapshot returns the elements of the queue in order.
+	// The result must be the content of the queue
+	// at a single point in time.
+	Snapshot() []interface{}
+}
+
+// Parameters holds the configuration parameters
+// for creating a new queue implementation.
+type Parameters struct {
+	// Flags are the configuration flags.
+	Flags Flags
+
+	// InitialCapacity is the requested initial capacity of the queue.
+	InitialCapacity int
+
+	// Limit is the capacity limit of a bounded queue,
+	// or 0 if the queue is unbounded.
+	Limit int
+
+	// Overflow is the overflow policy of a bounded queue.
+	Overflow OverflowPolicy
+
+	// ShrinkRatio is the shrink ratio of the queue (see Config.Shrink).
+	ShrinkRatio float64
+}
+
+// Constructor creates a new, empty queue implementation.
+// If the constructor cannot create a queue with the given parameters,
+// it returns nil, and Make moves on to the next best implementation.
+type Constructor func( params Parameters ) Implementation
+
+// implementation is an entry in the registry.
+type implementation struct {
+	name string
+	capabilities Flags
+	// newFactory creates a factory for the given configuration.
+	// If the configuration cannot be handled, nil is returned.
+	newFactory func( c *Config ) factory
+}
+
+// relaxations are the flags which permit an implementation
+// to provide weaker guarantees.
+// For all other flags, an implementation must provide the property
+// the flag requests.
+const relaxations = FNonConcurrent | FRelaxedOrder
+
+// registry holds all registered implementations in order of registration.
+var registry struct {
+	mx sync.RWMutex
+	implementations []implementation
+}
+
+// Register registers a queue implementation under the given name,
+// so that Make can use it.
+//
+// The capabilities describe the configurations the implementation can
+// handle:
+// FMultiReader and FMultiWriter mean that the implementation is safe for
+// concurrent use by multiple readers or writers, respectively.
+// Without these flags,
+// the implementation must still be safe for one reader and one writer
+// accessing it concurrently, unless FNonConcurrent is given.
+// FLockFree and FSplitLock mean that the implementation
+// has the respective property.
+// FBounded means that the implementation honours the Limit and Overflow
+// parameters.
+// FNonConcurrent and FRelaxedOrder mean that the implementation is
+// not safe for concurrent use or not strictly FIFO, respectively.
+// Such an implementation is only used if the configuration permits it.
+//
+// Make picks the implementation whose capabilities match the configuration
+// most closely,
+// i. e., the one with the fewest surplus concurrency capabilities
+// and the fewest unused relaxations.
+// Among equally close matches, the implementation registered first wins.
+// This way, the implementations built into this package,
+// which are registered first, remain the default choice.
+// Use Config.Implementation to select an implementation by name.
+//
+// Register returns an error if the name is empty or already taken,
+// or if the constructor is nil.
+func Register( name string, capabilities Flags, constructor Constructor ) error {
+	if constructor == nil {
+		return errors.New( "Constructor must not be nil" )
+	}
+
+	return register( name, capabilities, func( c *Config ) factory {
+		impl := constructor( c.parameters() )
+		if impl == nil {
+			return nil
+		}
+		return newImplementationFactory( impl )
+	} )
+}
+
+// register adds an implementation to the registry.
+func register( name string, capabilities Flags, newFactory func( c *Config ) factory ) error {
+	if name == "" {
+		return errors.New( "Implementation name must not be empty" )
+	}
+	registry.mx.Lock()
+	defer registry.mx.Unlock()
+	for _, impl := range registry.implementations {
+		if impl.name == name {
+			return fmt.Errorf( "Implementation '%s' already registered", name )
+		}
+	}
+	registry.implementations = append( registry.implementations, implementation{
+		name: name,
+		capabilities: capabilities &^ FNotImplemented,
+		newFactory: newFactory,
+	} )
+
+	return nil
+}
+
+// mismatch determines how well an implementation with the given capabilities
+// matches the given configuration flags.
+// If the implementation cannot handle the configuration,
+// ok is false.
+// Otherwise, cost counts the surplus concurrency capabilities and
+// the unused relaxations. Lower is better.
+func mismatch( capabilities, flags Flags ) ( cost int, ok bool ) {
+	flags &^= FNotImplemented
+	if ( capabilities & relaxations &^ flags ) != 0 {
+		return 0, false
+	}
+	if ( flags &^ relaxations &^ capabilities ) != 0 {
+		return 0, false
+	}
+	for bit := Flags( 1 ); bit != 0; bit <<= 1 {
+		if ( bit & ( FMultiReader | FMultiWriter ) & capabilities &^ flags ) != 0 {
+			cost++
+		}
+		if ( bit & relaxations & flags &^ capabilities ) != 0 {
+			cost++
+		}
+	}
+
+	return cost, true
+}
+
+// selectFactory returns a factory for the best implementation
+// for the given configuration,
+// or nil if there is no suitable implementation.
+// If accept is not nil, it is called with the factory of each candidate
+// in order of preference until it returns true.
+// It can be used to reject implementations lacking some functionality.
+func selectFactory( c *Config, accept func( f factory ) bool ) factory {
+	registry.mx.RLock()
+	candidates := make( []implementation, len( registry.implementations ) )
+	copy( candidates, registry.implementations )
+	registry.mx.RUnlock()
+	try := func( impl implementation ) factory {
+		f := impl.newFactory( c )
+		if ( f == nil ) || ( ( accept != nil ) && !accept( f ) ) {
+			return nil
+		}
+		return f
+	}
+	if c.implementation != "" {
+		for _, impl := range candidates {
+			if impl.name != c.implementation {
+				continue
+			}
+			if _, ok := mismatch( impl.capabilities, c.Flags ); !ok {
+				return nil
+			}
+			return try( impl )
+		}
+		return nil
+	}
+	// Try candidates in order of increasing cost,
+	// moving on if an implementation declines the configuration.
+	tried := make( []bool, len( candidates ) )
+	for {
+		best := -1
+		bestCost := 0
+		for i, impl := range candidates {
+			if tried[i] {
+				continue
+			}
+			cost, ok := mismatch( impl.capabilities, c.Flags )
+			if !ok {
+				tried[i] = true
+				continue
+			}
+			if ( best < 0 ) || ( cost < bestCost ) {
+				best = i
+				bestCost = cost
+			}
+		}
+		if best < 0 {
+			return nil
+		}
+		if f := try( candidates[best] ); f != nil {
+			return f
+		}
+		tried[best] = true
+	}
+}
+
+// implementationQueue adapts an Implementation to the internal queue
+// interface.
+type implementationQueue struct {
+	impl Implementation
+}
+
+func ( q *implementationQueue ) enqueue( x interface{} ) {
+	q.impl.Enqueue( x )
+}
+
+func ( q *implementationQueue ) dequeue() ( interface{}, bool ) {
+	return q.impl.Dequeue()
+}
+
+// implementationSizer adapts a Sizer to the internal sizeQueue interface.
+type implementationSizer struct {
+	sizer Sizer
+}
+
+func ( s implementationSizer ) count() int {
+	return s.sizer.Len()
+}
+
+func ( s implementationSizer ) capacity() int {
+	return s.sizer.Cap()
+}
+
+// implementationPeeker adapts a Peeker to the internal peekQueue interface.
+type implementationPeeker struct {
+	peeker Peeker
+}
+
+func ( p implementationPeeker ) peek() ( interface{}, bool ) {
+	return p.peeker.Peek()
+}
+
+// implementationDrainer adapts a Drainer to the internal drainQueue interface.
+type implementationDrainer struct {
+	drainer Drainer
+}
+
+func ( d implementationDrainer ) drain() []interface{} {
+	return d.drainer.Drain()
+}
+
+func ( d implementationDrainer ) clear() int {
+	return d.drainer.Clear()
+}
+
+// implementationSnapshotter adapts a Snapshotter
+// to the internal snapshotQueue interface.
+type implementationSnapshotter struct {
+	snapshotter Snapshotter
+}
+
+func ( s implementationSnapshotter ) snapshot() []interface{} {
+	return s.snapshotter.Snapshot()
+}
+
+// implementationFactory implements factory for registered implementations.
+// The implementation has already been created when the factory is created,
+// so that the constructor can decline the configuration.
+type implementationFactory struct {
+	iq *implementationQueue
+}
+
+func newImplementationFactory( impl Implementation ) factory {
+	return &implementationFactory{
+		iq: &implementationQueue{
+			impl: impl,
+		},
+	}
+}
+
+func ( iqf *implementationFactory ) prepare() {
+	// empty
+}
+
+func ( iqf *implementationFactory ) commit() {
+	// empty
+}
+
+func ( iqf *implementationFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( iqf.iq, methodType )
+}
+
+func ( iqf *implementationFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( iqf.iq, methodType )
+}
+
+func ( iqf *implementationFactory ) queue() interfaceQueue {
+	return iqf.iq
+}
+
+func ( iqf *implementationFactory ) reset() {
+	iqf.iq = nil
+}
+
+// Built-in implementations, in order of preference.
+func init() {
+	builtins := []implementation{
+		{ "simple", FNonConcurrent | FBounded, func( c *Config ) factory {
+			return newBoundedSimpleQueueFactory( c.initialCapacity, c.limit, c.overflow, c.effectiveShrinkRatio() )
+		} },
+		{ "spsc", FLockFree | FSplitLock, func( c *Config ) factory {
+			return newSpscQueueFactory( c.initialCapacity )
+		} },
+		{ "sharded", FMultiReader | FMultiWriter | FRelaxedOrder, func( c *Config ) factory {
+			return newShardedQueueFactory( runtime.GOMAXPROCS( 0 ), c.initialCapacity, c.effectiveShrinkRatio() )
+		} },
+		{ "mpsc", FMultiWriter | FLockFree | FSplitLock, func( c *Config ) factory {
+			return newMpscQueueFactory()
+		} },
+		{ "bounded", FMultiReader | FMultiWriter | FLockFree | FSplitLock | FBounded, func( c *Config ) factory {
+			if ( c.Flags & FBounded ) == 0 {
+				return nil
+			}
+			// Blocking writers wait behind a lock (see overflowQueue).
+			if ( c.overflow == OverflowBlock ) && ( ( c.Flags & ( FLockFree | FSplitLock ) ) != 0 ) {
+				return nil
+			}
+			return newBoundedQueueFactory( c.limit, c.overflow )
+		} },
+		{ "locked", FMultiReader | FMultiWriter | FBounded, func( c *Config ) factory {
+			return newBoundedLockedQueueFactory( c.initialCapacity, c.limit, c.overflow, c.effectiveShrinkRatio() )
+		} },
+		{ "twolock", FMultiReader | FMultiWriter | FSplitLock, func( c *Config ) factory {
+			return newTwoLockQueueFactory()
+		} },
+		{ "ms", FMultiReader | FMultiWriter | FLockFree | FSplitLock, func( c *Config ) factory {
+			return newMsQueueFactory()
+		} },
+	}
+	for _, impl := range builtins {
+		if err := register( impl.name, impl.capabilities, impl.newFactory ); err != nil {
+			panic( err )
+		}
+	}
+}
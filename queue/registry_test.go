@@ -0,0 +1,228 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync"
+	"testing"
+)
+
+// unregister removes the implementation with the given name
+// from the registry, if there is one.
+// Tests use it to clean up after themselves.
+func unregister( name string ) {
+	registry.mx.Lock()
+	defer registry.mx.Unlock()
+	for i, impl := range registry.implementations {
+		if impl.name == name {
+			registry.implementations = append( registry.implementations[:i], registry.implementations[i + 1:]... )
+			return
+		}
+	}
+}
+
+// sliceImplementation is a trivial Implementation for testing.
+type sliceImplementation struct {
+	mx sync.Mutex
+	elements []interface{}
+}
+
+func ( s *sliceImplementation ) Enqueue( x interface{} ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.elements = append( s.elements, x )
+}
+
+func ( s *sliceImplementation ) Dequeue() ( interface{}, bool ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if len( s.elements ) == 0 {
+		return nil, false
+	}
+	x := s.elements[0]
+	s.elements = s.elements[1:]
+	return x, true
+}
+
+// sliceImplementation implements Sizer.
+func ( s *sliceImplementation ) Len() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return len( s.elements )
+}
+
+func ( s *sliceImplementation ) Cap() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return cap( s.elements )
+}
+
+// sliceImplementation implements Drainer.
+func ( s *sliceImplementation ) Drain() []interface{} {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	xs := s.elements
+	s.elements = nil
+	return xs
+}
+
+func ( s *sliceImplementation ) Clear() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	n := len( s.elements )
+	s.elements = nil
+	return n
+}
+
+// sliceImplementation implements Snapshotter.
+func ( s *sliceImplementation ) Snapshot() []interface{} {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return append( []interface{}( nil ), s.elements... )
+}
+
+// sliceImplementation implements Peeker.
+func ( s *sliceImplementation ) Peek() ( interface{}, bool ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if len( s.elements ) == 0 {
+		return nil, false
+	}
+	return s.elements[0], true
+}
+
+// sliceNoPeekImplementation hides the Peek method of sliceImplementation.
+type sliceNoPeekImplementation struct {
+	impl sliceImplementation
+}
+
+func ( s *sliceNoPeekImplementation ) Enqueue( x interface{} ) {
+	s.impl.Enqueue( x )
+}
+
+func ( s *sliceNoPeekImplementation ) Dequeue() ( interface{}, bool ) {
+	return s.impl.Dequeue()
+}
+
+func TestRegister( t *testing.T ) {
+	constructor := func( params Parameters ) Implementation {
+		return &sliceImplementation{}
+	}
+	if err := Register( "", FMultiReader | FMultiWriter, constructor ); err == nil {
+		t.Error( "Registration with empty name succeeds" )
+	}
+	if err := Register( "test-nil", FMultiReader | FMultiWriter, nil ); err == nil {
+		t.Error( "Registration with nil constructor succeeds" )
+	}
+	if err := Register( "locked", FMultiReader | FMultiWriter, constructor ); err == nil {
+		t.Error( "Registration with duplicate name succeeds" )
+	}
+	if err := Register( "test-slice", FMultiReader | FMultiWriter, constructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	defer unregister( "test-slice" )
+	// Built-in implementations win ties
+	if _, ok := DefaultConfig().factory().( *lockedQueueFactory ); !ok {
+		t.Error( "Registration changes default implementation" )
+	}
+	// Pinned implementation must be used
+	var q GenericQueue
+	if err := Make( &q, DefaultConfig().Implementation( "test-slice" ) ); err != nil {
+		t.Fatalf( "Unable to make queue with registered implementation: %s", err )
+	}
+	q.Enqueue( 1 )
+	q.Enqueue( 2 )
+	for i := 1; i <= 2; i++ {
+		x, ok := q.Dequeue()
+		if !ok || ( x != i ) {
+			t.Errorf( "Dequeue returned %v, %t, expected %d, true", x, ok, i )
+		}
+	}
+	if _, ok := q.Dequeue(); ok {
+		t.Error( "Dequeue succeeds on empty queue" )
+	}
+	if err := Make( &q, DefaultConfig().LockFree().Implementation( "test-slice" ) ); err == nil {
+		t.Error( "Pinned implementation used although it is not lock-free" )
+	}
+}
+
+func TestRegisterDecline( t *testing.T ) {
+	var params Parameters
+	constructor := func( p Parameters ) Implementation {
+		params = p
+		if p.Limit != 7 {
+			return nil
+		}
+		return &sliceImplementation{}
+	}
+	if err := Register( "test-decline", FMultiReader | FMultiWriter | FBounded | FRelaxedOrder, constructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	defer unregister( "test-decline" )
+	config := DefaultConfig().RelaxedOrder().Bounded( 5 ).Overflow( OverflowDropOldest )
+	if _, ok := config.factory().( *implementationFactory ); ok {
+		t.Error( "Declining implementation selected" )
+	}
+	if params.Flags != config.Flags || params.Limit != 5 || params.Overflow != OverflowDropOldest {
+		t.Errorf( "Bad parameters passed to constructor: %+v", params )
+	}
+	config.Bounded( 7 )
+	if _, ok := config.factory().( *implementationFactory ); !ok {
+		t.Error( "Best matching registered implementation not selected" )
+	}
+}
+
+func TestMismatch( t *testing.T ) {
+	if _, ok := mismatch( FMultiWriter, FMultiReader ); ok {
+		t.Error( "Single reader implementation matches multi reader configuration" )
+	}
+	if _, ok := mismatch( FNonConcurrent, 0 ); ok {
+		t.Error( "Non-concurrent implementation matches concurrent configuration" )
+	}
+	if _, ok := mismatch( FMultiReader | FMultiWriter | FRelaxedOrder, FMultiReader | FMultiWriter ); ok {
+		t.Error( "Relaxed order implementation matches strict order configuration" )
+	}
+	if cost, ok := mismatch( FMultiReader | FMultiWriter | FLockFree, FMultiReader | FMultiWriter ); !ok || ( cost != 0 ) {
+		t.Errorf( "Exact concurrency match has cost %d, ok %t", cost, ok )
+	}
+	if cost, ok := mismatch( FMultiReader | FMultiWriter, FMultiWriter | FRelaxedOrder ); !ok || ( cost != 2 ) {
+		t.Errorf( "Surplus reader and unused relaxation have cost %d, ok %t, expected 2", cost, ok )
+	}
+}
+
+func TestUnregister( t *testing.T ) {
+	constructor := func( params Parameters ) Implementation {
+		return &sliceImplementation{}
+	}
+	if err := Register( "test-unregister", FMultiReader | FMultiWriter, constructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	unregister( "test-unregister" )
+	if DefaultConfig().Implementation( "test-unregister" ).factory() != nil {
+		t.Error( "Unregistered implementation still selectable" )
+	}
+	if err := Register( "test-unregister", FMultiReader | FMultiWriter, constructor ); err != nil {
+		t.Errorf( "Registration after unregister failed: %s", err )
+	}
+	unregister( "test-unregister" )
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync"
+)
+
+// sequenced carries an element along with its sequence number
+// between the typed functions and a sequenceQueue.
+// It is never stored in a queue:
+// a sequenceQueue unwraps enqueued elements,
+// and wraps dequeued elements only on the way to the typed dequeue
+// function returning sequence numbers.
+// The typed functions unwrap them (see elementValue).
+type sequenced struct {
+	seq uint64
+	value interface{}
+}
+
+// sequenceQueue wraps a strictly ordered FIFO queue
+// to assign consecutive sequence numbers to the enqueued elements,
+// starting at 1.
+// The sequence numbers are not stored with the elements.
+// Instead, elements are enqueued while holding enqueueMx
+// and removed while holding dequeueMx,
+// and both sides count the elements.
+// Since the queue is FIFO,
+// the n-th removed element is the n-th enqueued element,
+// so its sequence number is n.
+// For bounded queues, the sequenceQueue sits between the overflowQueue
+// and the bounded queue, so that writers waiting for space
+// do not hold enqueueMx,
+// and elements dropped due to the overflow policy are counted as removed.
+// Elements which cannot be enqueued do not use up a sequence number.
+type sequenceQueue struct {
+	queue interfaceQueue
+	enqueueMx sync.Mutex
+	// enqueued is the number of enqueued elements,
+	// and thus the sequence number of the most recently enqueued element.
+	// It is protected by enqueueMx.
+	enqueued uint64
+	dequeueMx sync.Mutex
+	// removed is the number of removed elements,
+	// and thus the sequence number of the most recently removed element.
+	// It is protected by dequeueMx.
+	removed uint64
+	// tag indicates whether dequeue returns elements as *sequenced.
+	tag bool
+}
+
+// newSequenceQueue creates a new sequenceQueue wrapping queue.
+// If tag is true, dequeue returns the elements as *sequenced.
+func newSequenceQueue( queue interfaceQueue, tag bool ) *sequenceQueue {
+	return &sequenceQueue{
+		queue: queue,
+		tag: tag,
+	}
+}
+
+// enqueue enqueues x.
+// Writers interested in the sequence number pass a *sequenced
+// and read its sequence number once the element has been enqueued.
+func ( q *sequenceQueue ) enqueue( x interface{} ) {
+	sx, ticket := x.( *sequenced )
+	if ticket {
+		x = sx.value
+	}
+	q.enqueueMx.Lock()
+	defer q.enqueueMx.Unlock()
+	q.queue.enqueue( x )
+	q.enqueued++
+	if ticket {
+		sx.seq = q.enqueued
+	}
+}
+
+func ( q *sequenceQueue ) tryEnqueue( x interface{} ) bool {
+	sx, ticket := x.( *sequenced )
+	if ticket {
+		x = sx.value
+	}
+	q.enqueueMx.Lock()
+	defer q.enqueueMx.Unlock()
+	if !tryEnqueue( q.queue, x ) {
+		return false
+	}
+	q.enqueued++
+	if ticket {
+		sx.seq = q.enqueued
+	}
+
+	return true
+}
+
+func ( q *sequenceQueue ) enqueueBatch( xs []interface{} ) {
+	q.enqueueMx.Lock()
+	defer q.enqueueMx.Unlock()
+	enqueueBatch( q.queue, xs )
+	q.enqueued += uint64( len( xs ) )
+}
+
+func ( q *sequenceQueue ) tryEnqueueBatch( xs []interface{} ) int {
+	q.enqueueMx.Lock()
+	defer q.enqueueMx.Unlock()
+	n := tryEnqueueBatch( q.queue, xs )
+	q.enqueued += uint64( n )
+
+	return n
+}
+
+func ( q *sequenceQueue ) dequeue() ( x interface{}, ok bool ) {
+	q.dequeueMx.Lock()
+	defer q.dequeueMx.Unlock()
+	x, ok = q.queue.dequeue()
+	if ok {
+		q.removed++
+		if q.tag {
+			x = &sequenced{
+				seq: q.removed,
+				value: x,
+			}
+		}
+	}
+
+	return
+}
+
+func ( q *sequenceQueue ) dequeueBatch( dst []interface{} ) int {
+	q.dequeueMx.Lock()
+	defer q.dequeueMx.Unlock()
+	n := dequeueBatch( q.queue, dst )
+	q.removed += uint64( n )
+
+	return n
+}
+
+// drain drains the wrapped queue, which must support it
+// (see asDrainQueue).
+func ( q *sequenceQueue ) drain() []interface{} {
+	q.dequeueMx.Lock()
+	defer q.dequeueMx.Unlock()
+	xs := asDrainQueue( q.queue ).drain()
+	q.removed += uint64( len( xs ) )
+
+	return xs
+}
+
+// clear works like drain.
+func ( q *sequenceQueue ) clear() int {
+	q.dequeueMx.Lock()
+	defer q.dequeueMx.Unlock()
+	n := asDrainQueue( q.queue ).clear()
+	q.removed += uint64( n )
+
+	return n
+}
+
+func ( q *sequenceQueue ) unwrap() interfaceQueue {
+	return q.queue
+}
+
+// makeEnqueueSeq creates the function interfacing the typed enqueue
+// function returning sequence numbers
+// with the generic implementation of the queue.
+// The queue must wrap a sequenceQueue,
+// which takes the element out of the *sequenced passed to it.
+// Elements discarded due to the overflow policy get the sequence number 0.
+func makeEnqueueSeq( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		sx := &sequenced{
+			value: args[0].Interface(),
+		}
+		q.enqueue( sx )
+		return []reflect.Value{
+			reflect.ValueOf( sx.seq ).Convert( methodType.Out( 0 ) ),
+		}
+	} )
+}
+
+// makeDequeueSeq creates the function interfacing the typed dequeue
+// function returning sequence numbers
+// with the generic implementation of the queue.
+// The queue must wrap a sequenceQueue which tags dequeued elements.
+// If no element could be dequeued, the sequence number is 0.
+func makeDequeueSeq( q interfaceQueue, methodType reflect.Type ) reflect.Value {
+	return reflect.MakeFunc( methodType, func( args []reflect.Value ) []reflect.Value {
+		x, ok := q.dequeue()
+		seq := uint64( 0 )
+		if sx, isSequenced := x.( *sequenced ); ok && isSequenced {
+			seq = sx.seq
+		}
+		return []reflect.Value{
+			elementValue( x, ok, methodType.Out( 0 ) ),
+			reflect.ValueOf( seq ).Convert( methodType.Out( 1 ) ),
+			reflect.ValueOf( ok ).Convert( methodType.Out( 2 ) ),
+		}
+	} )
+}
@@ -0,0 +1,244 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// seqTestQueue is used to test sequence numbers.
+type seqTestQueue struct {
+	Enqueue func( int ) uint64 `queue:"enqueue"`
+	Dequeue func() ( int, uint64, bool ) `queue:"dequeue"`
+	Peek func() ( int, bool ) `queue:"peek"`
+}
+
+// seqDequeueTestQueue assigns sequence numbers without returning them
+// from the enqueue function.
+type seqDequeueTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	EnqueueBatch func( ...int ) `queue:"enqueueBatch"`
+	Dequeue func() ( int, uint64, bool ) `queue:"dequeue"`
+}
+
+func TestSequence( t *testing.T ) {
+	const n = 50
+	for _, config := range filterConfigs( allConfigs( 100 ), sequencingConfig ) {
+		var q seqTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		for i := 0; i < n; i++ {
+			if seq := q.Enqueue( i ); seq != uint64( i + 1 ) {
+				t.Errorf( "Enqueue returned sequence number %d, expected %d with flags %x", seq, i + 1, config.Flags )
+			}
+		}
+		if x, ok := q.Peek(); !ok || ( x != 0 ) {
+			t.Errorf( "Peek returned %d, %t with flags %x", x, ok, config.Flags )
+		}
+		for i := 0; i < n; i++ {
+			x, seq, ok := q.Dequeue()
+			if !ok || ( x != i ) || ( seq != uint64( i + 1 ) ) {
+				t.Errorf( "Dequeue returned %d, %d, %t, expected %d, %d, true with flags %x", x, seq, ok, i, i + 1, config.Flags )
+			}
+		}
+		if x, seq, ok := q.Dequeue(); ok || ( x != 0 ) || ( seq != 0 ) {
+			t.Errorf( "Dequeue from empty queue returned %d, %d, %t with flags %x", x, seq, ok, config.Flags )
+		}
+	}
+}
+
+func TestSequenceDequeueOnly( t *testing.T ) {
+	var q seqDequeueTestQueue
+	if err := Make( &q, nil ); err != nil {
+		t.Fatal( err )
+	}
+	q.Enqueue( 0 )
+	q.EnqueueBatch( 1, 2, 3 )
+	q.Enqueue( 4 )
+	for i := 0; i < 5; i++ {
+		x, seq, ok := q.Dequeue()
+		if !ok || ( x != i ) || ( seq != uint64( i + 1 ) ) {
+			t.Errorf( "Dequeue returned %d, %d, %t, expected %d, %d, true", x, seq, ok, i, i + 1 )
+		}
+	}
+}
+
+// seqDrainTestQueue is used to test that sequence numbers
+// are not stored with the elements.
+type seqDrainTestQueue struct {
+	Enqueue func( int ) uint64 `queue:"enqueue"`
+	Dequeue func() ( int, uint64, bool ) `queue:"dequeue"`
+	Drain func() []int `queue:"drain"`
+	Clear func() `queue:"clear"`
+}
+
+// seqLessTestQueue is a priority queue with sequence numbers.
+type seqLessTestQueue struct {
+	Enqueue func( int ) uint64 `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Less func( int, int ) bool `queue:"less"`
+}
+
+func TestSequenceRejected( t *testing.T ) {
+	for _, config := range filterConfigs( allConfigs( 100 ), func( c *Config ) bool {
+		return !sequencingConfig( c )
+	} ) {
+		var q seqTestQueue
+		if err := Make( &q, config ); err == nil {
+			t.Errorf( "Make with sequence numbers succeeds with flags %x", config.Flags )
+		}
+		var qd seqDequeueTestQueue
+		if err := Make( &qd, config ); err == nil {
+			t.Errorf( "Make with dequeue sequence numbers succeeds with flags %x", config.Flags )
+		}
+	}
+	var q seqLessTestQueue
+	q.Less = func( x, y int ) bool {
+		return x < y
+	}
+	if err := Make( &q, nil ); err == nil {
+		t.Error( "Make with sequence numbers succeeds for priority queue" )
+	}
+}
+
+func TestSequenceImplementation( t *testing.T ) {
+	impl := &sliceImplementation{}
+	constructor := func( params Parameters ) Implementation {
+		return impl
+	}
+	if err := Register( "test-seq", FMultiReader | FMultiWriter, constructor ); err != nil {
+		t.Fatalf( "Registration failed: %s", err )
+	}
+	defer unregister( "test-seq" )
+	var q seqDrainTestQueue
+	if err := Make( &q, DefaultConfig().Implementation( "test-seq" ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	for i := 0; i < 4; i++ {
+		q.Enqueue( i )
+	}
+	// The implementation sees the plain elements.
+	if xs := impl.Snapshot(); !reflect.DeepEqual( xs, []interface{}{ 0, 1, 2, 3 } ) {
+		t.Errorf( "Implementation stores %v", xs )
+	}
+	if x, seq, ok := q.Dequeue(); !ok || ( x != 0 ) || ( seq != 1 ) {
+		t.Errorf( "Dequeue returned %d, %d, %t, expected 0, 1, true", x, seq, ok )
+	}
+	if xs := q.Drain(); !reflect.DeepEqual( xs, []int{ 1, 2, 3 } ) {
+		t.Errorf( "Drain returned %v", xs )
+	}
+	// Drained and cleared elements use up their sequence numbers.
+	q.Enqueue( 4 )
+	q.Clear()
+	if seq := q.Enqueue( 5 ); seq != 6 {
+		t.Errorf( "Enqueue returned sequence number %d, expected 6", seq )
+	}
+	if x, seq, ok := q.Dequeue(); !ok || ( x != 5 ) || ( seq != 6 ) {
+		t.Errorf( "Dequeue returned %d, %d, %t, expected 5, 6, true", x, seq, ok )
+	}
+}
+
+func TestSequenceOverflow( t *testing.T ) {
+	var q seqTestQueue
+	if err := Make( &q, DefaultConfig().Bounded( 2 ).Overflow( OverflowDropNewest ) ); err != nil {
+		t.Fatal( err )
+	}
+	for i, expected := range []uint64{ 1, 2, 0 } {
+		if seq := q.Enqueue( i ); seq != expected {
+			t.Errorf( "DropNewest: Enqueue returned sequence number %d, expected %d", seq, expected )
+		}
+	}
+	q.Dequeue()
+	if seq := q.Enqueue( 3 ); seq != 3 {
+		t.Errorf( "DropNewest: Enqueue returned sequence number %d, expected 3", seq )
+	}
+	if err := Make( &q, DefaultConfig().Bounded( 2 ).Overflow( OverflowDropOldest ) ); err != nil {
+		t.Fatal( err )
+	}
+	for i := 0; i < 3; i++ {
+		q.Enqueue( i )
+	}
+	// The gap reveals the dropped element.
+	for i := 1; i < 3; i++ {
+		x, seq, ok := q.Dequeue()
+		if !ok || ( x != i ) || ( seq != uint64( i + 1 ) ) {
+			t.Errorf( "DropOldest: Dequeue returned %d, %d, %t, expected %d, %d, true", x, seq, ok, i, i + 1 )
+		}
+	}
+}
+
+func TestSequenceConcurrent( t *testing.T ) {
+	const writers = 4
+	const n = 500
+	configs := []*Config{
+		DefaultConfig().SingleReader(),
+		DefaultConfig(),
+		DefaultConfig().SplitLock(),
+		DefaultConfig().Bounded( 16 ).Overflow( OverflowBlock ),
+	}
+	for _, config := range configs {
+		var q seqTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		// Each writer records the sequence numbers of its elements.
+		seqs := make( [][]uint64, writers )
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			seqs[w] = make( []uint64, n )
+			wg.Add( 1 )
+			go func( w int ) {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					seqs[w][i] = q.Enqueue( w * n + i )
+				}
+			}( w )
+		}
+		// The reader must see the sequence numbers without gaps.
+		dequeued := make( []uint64, writers * n )
+		for expected := uint64( 1 ); expected <= writers * n; {
+			x, seq, ok := q.Dequeue()
+			if !ok {
+				runtime.Gosched()
+				continue
+			}
+			if seq != expected {
+				t.Fatalf( "Dequeued sequence number %d, expected %d with flags %x", seq, expected, config.Flags )
+			}
+			dequeued[x] = seq
+			expected++
+		}
+		wg.Wait()
+		for w := 0; w < writers; w++ {
+			for i := 0; i < n; i++ {
+				if seqs[w][i] != dequeued[w * n + i] {
+					t.Fatalf( "Element %d enqueued with sequence number %d but dequeued with %d with flags %x", w * n + i, seqs[w][i], dequeued[w * n + i], config.Flags )
+				}
+			}
+		}
+	}
+}
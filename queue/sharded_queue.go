@@ -0,0 +1,272 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync/atomic"
+)
+
+// shardedQueue spreads its elements over several locked queues (shards)
+// to reduce contention between writers.
+// Writers are striped across the shards in round-robin fashion,
+// and readers visit the shards in round-robin fashion,
+// moving on to the next shard if a shard is empty.
+// Each shard is FIFO, but the queue as a whole is not:
+// concurrent writers and readers, as well as empty shards,
+// may cause elements to be dequeued in a different order
+// than they were enqueued.
+// Without concurrent access, the order is FIFO.
+type shardedQueue struct {
+	shards []*lockedQueue
+	_ [cacheLineSize]byte
+	// enqueueCursor selects the shard for the next enqueue operation.
+	// It must only be accessed atomically.
+	enqueueCursor uint64
+	_ [cacheLineSize]byte
+	// dequeueCursor selects the first shard for the next dequeue operation.
+	// It must only be accessed atomically.
+	dequeueCursor uint64
+	_ [cacheLineSize]byte
+}
+
+// newShardedQueue creates a new sharded queue.
+// The remaining arguments are passed on to newLockedQueue for each shard.
+func newShardedQueue( shards, initialCapacity, segmentSize int, shrinkRatio float64 ) *shardedQueue {
+	q := &shardedQueue{
+		shards: make( []*lockedQueue, shards ),
+	}
+	for i := range q.shards {
+		q.shards[i] = newLockedQueue( initialCapacity, segmentSize, shrinkRatio )
+	}
+
+	return q
+}
+
+func ( q *shardedQueue ) enqueue( x interface{} ) {
+	cursor := atomic.AddUint64( &q.enqueueCursor, 1 ) - 1
+	q.shards[cursor % uint64( len( q.shards ) )].enqueue( x )
+}
+
+// dequeue tries each shard once, starting with the shard selected by
+// the dequeue cursor.
+// It fails only if all shards were empty at the time they were visited.
+// Afterwards, the dequeue cursor points to the shard after the one
+// the element was dequeued from,
+// or remains unchanged if the dequeue operation failed.
+func ( q *shardedQueue ) dequeue() ( x interface{}, ok bool ) {
+	cursor := atomic.AddUint64( &q.dequeueCursor, 1 ) - 1
+	n := uint64( len( q.shards ) )
+	for i := uint64( 0 ); i < n; i++ {
+		x, ok = q.shards[( cursor + i ) % n].dequeue()
+		if ok {
+			if i != 0 {
+				atomic.AddUint64( &q.dequeueCursor, i )
+			}
+			return
+		}
+	}
+	atomic.AddUint64( &q.dequeueCursor, ^uint64( 0 ) )
+
+	return
+}
+
+// enqueueBatch puts all elements into the same shard,
+// so they keep their order.
+func ( q *shardedQueue ) enqueueBatch( xs []interface{} ) {
+	cursor := atomic.AddUint64( &q.enqueueCursor, 1 ) - 1
+	q.shards[cursor % uint64( len( q.shards ) )].enqueueBatch( xs )
+}
+
+// dequeueBatch visits the shards like dequeue
+// until dst is full or all shards have been visited.
+func ( q *shardedQueue ) dequeueBatch( dst []interface{} ) int {
+	cursor := atomic.AddUint64( &q.dequeueCursor, 1 ) - 1
+	shards := uint64( len( q.shards ) )
+	n := 0
+	i := uint64( 0 )
+	for ; ( i < shards ) && ( n < len( dst ) ); i++ {
+		n += q.shards[( cursor + i ) % shards].dequeueBatch( dst[n:] )
+	}
+	if n == 0 {
+		atomic.AddUint64( &q.dequeueCursor, ^uint64( 0 ) )
+	} else if i > 1 {
+		atomic.AddUint64( &q.dequeueCursor, i - 1 )
+	}
+
+	return n
+}
+
+// drain locks all shards
+// and then removes the elements in the same order as repeated dequeues.
+func ( q *shardedQueue ) drain() []interface{} {
+	xs, _ := q.drainShards( true )
+
+	return xs
+}
+
+func ( q *shardedQueue ) clear() int {
+	_, n := q.drainShards( false )
+
+	return n
+}
+
+// drainShards implements drain.
+// It returns the number n of removed elements.
+// The removed elements are returned only if keep is true.
+// Otherwise, the storage of the shards is released.
+func ( q *shardedQueue ) drainShards( keep bool ) ( xs []interface{}, n int ) {
+	for _, shard := range q.shards {
+		shard.mx.Lock()
+		defer shard.mx.Unlock()
+		n += shard.simpleQueue.count()
+	}
+	cursor := atomic.LoadUint64( &q.dequeueCursor )
+	shards := uint64( len( q.shards ) )
+	pos := cursor
+	if keep {
+		xs = make( []interface{}, 0, n )
+	}
+	for remaining := n; remaining > 0; pos++ {
+		x, ok := q.shards[pos % shards].simpleQueue.dequeue()
+		if ok {
+			if keep {
+				xs = append( xs, x )
+			}
+			remaining--
+		}
+	}
+	if !keep {
+		for _, shard := range q.shards {
+			shard.simpleQueue.clear()
+		}
+	}
+	// Concurrent dequeue operations may have moved the cursor.
+	atomic.AddUint64( &q.dequeueCursor, pos - cursor )
+
+	return
+}
+
+func ( q *shardedQueue ) count() int {
+	n := 0
+	for _, shard := range q.shards {
+		n += shard.count()
+	}
+
+	return n
+}
+
+func ( q *shardedQueue ) capacity() int {
+	n := 0
+	for _, shard := range q.shards {
+		n += shard.capacity()
+	}
+
+	return n
+}
+
+// peek visits the shards in the same order as dequeue.
+func ( q *shardedQueue ) peek() ( x interface{}, ok bool ) {
+	cursor := atomic.LoadUint64( &q.dequeueCursor )
+	n := uint64( len( q.shards ) )
+	for i := uint64( 0 ); i < n; i++ {
+		x, ok = q.shards[( cursor + i ) % n].peek()
+		if ok {
+			return
+		}
+	}
+
+	return
+}
+
+// snapshot locks all shards
+// and lists the elements in the same order as repeated dequeues would.
+func ( q *shardedQueue ) snapshot() []interface{} {
+	total := 0
+	shards := make( [][]interface{}, len( q.shards ) )
+	for i, shard := range q.shards {
+		shard.mx.Lock()
+		defer shard.mx.Unlock()
+		shards[i] = shard.simpleQueue.snapshot()
+		total += len( shards[i] )
+	}
+	cursor := atomic.LoadUint64( &q.dequeueCursor )
+	n := uint64( len( shards ) )
+	xs := make( []interface{}, 0, total )
+	for pos := cursor; len( xs ) < total; pos++ {
+		shard := &shards[pos % n]
+		if len( *shard ) != 0 {
+			xs = append( xs, ( *shard )[0] )
+			*shard = ( *shard )[1:]
+		}
+	}
+
+	return xs
+}
+
+// shardedQueueFactory implements factory for shardedQueue
+type shardedQueueFactory struct {
+	segFactory
+	shards int
+	sq *shardedQueue
+}
+
+func ( sqf *shardedQueueFactory ) prepare() {
+	sqf.sq = newShardedQueue( sqf.shards, sqf.initialCapacity, sqf.segmentSize, sqf.shrinkRatio )
+}
+
+func ( sqf *shardedQueueFactory ) commit() {
+	// empty
+}
+
+func ( sqf *shardedQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( sqf.sq, methodType )
+}
+
+func ( sqf *shardedQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( sqf.sq, methodType )
+}
+
+func ( sqf *shardedQueueFactory ) queue() interfaceQueue {
+	return sqf.sq
+}
+
+func ( sqf *shardedQueueFactory ) reset() {
+	sqf.sq = nil
+}
+
+// newShardedQueueFactory creates a factory for sharded queues
+// with the given number of shards.
+// The initial capacity is divided evenly among the shards.
+// Values too small will be corrected.
+func newShardedQueueFactory( shards, initialCapacity int, shrinkRatio float64 ) factory {
+	if shards < 1 {
+		shards = 1
+	}
+
+	return &shardedQueueFactory{
+		segFactory: *newSegFactory( initialCapacity / shards, 0, shrinkRatio ),
+		shards: shards,
+		sq: nil,
+	}
+}
@@ -26,61 +26,301 @@ import(
 	"reflect"
 )
 
+// segment is a chunk of storage for a simpleQueue.
+type segment struct {
+	elements []interface{}
+	next *segment
+}
+
 // simpleQueue keeps the data for a simple, non-concurrent queue.
+// The elements are stored in a linked list of segments.
+// The first segment is sized to the initial capacity,
+// so that small queues do not allocate a full segment up front.
+// Further segments grow with the queue (see newSegment),
+// so that a burst needs only a few allocations.
+// Segments which have been emptied are kept on a free list
+// and reused before new segments are allocated.
+// The shrink ratio limits the size of the free list,
+// so that storage needed only during a burst is eventually released.
 type simpleQueue struct {
-	buf1 []interface{}
-	buf2 []interface{}
-	start, end int
+	// head is the segment holding the oldest element.
+	head *segment
+	// tail is the segment holding the newest element.
+	tail *segment
+	// start is the index of the oldest element in head.
+	start int
+	// end is the index after the newest element in tail.
+	end int
+	// length is the number of elements in the queue.
+	length int
+	// slots is the number of elements all segments can hold,
+	// including the segments in the free list.
+	slots int
+	// free is the list of recycled segments.
+	free *segment
+	// spareSlots is the number of elements the free list can hold.
+	spareSlots int
+	// initialSlots is the initial value of slots.
+	// The queue never shrinks below its initial capacity.
+	initialSlots int
+	// shrinkRatio is the maximum ratio of spare capacity
+	// to the number of elements in the queue.
+	// A negative value means that spare segments are never released.
+	shrinkRatio float64
+	// segmentSize is the minimum number of elements
+	// per newly allocated segment.
+	segmentSize int
+	// limit is the maximum number of elements in the queue.
+	// A value of 0 means the queue is unbounded.
+	limit int
 }
 
-func ( q *simpleQueue ) enqueue( x interface{} ) {
-	if q.end >= len( q.buf1 ) {
-		if q.end >= len( q.buf1 ) + len( q.buf2 ) {
-			q.buf2 = append( q.buf2, x )
-		} else {
-			q.buf2[q.end - len( q.buf1 )] = x
+// newSimpleQueue creates a new, unbounded simple queue
+// with the given initial capacity,
+// segments of the given size,
+// and the given shrink ratio.
+// The first segment holds initialCapacity elements,
+// up to segmentSize.
+// The remaining initial capacity is allocated in segments of segmentSize.
+func newSimpleQueue( initialCapacity, segmentSize int, shrinkRatio float64 ) *simpleQueue {
+	first := initialCapacity
+	if first < 1 {
+		first = 1
+	} else if first > segmentSize {
+		first = segmentSize
+	}
+	q := &simpleQueue{
+		head: &segment{
+			elements: make( []interface{}, first ),
+		},
+		start: 0,
+		end: 0,
+		length: 0,
+		slots: first,
+		free: nil,
+		spareSlots: 0,
+		shrinkRatio: shrinkRatio,
+		segmentSize: segmentSize,
+		limit: 0,
+	}
+	q.tail = q.head
+	for q.slots < initialCapacity {
+		q.slots += segmentSize
+		q.recycle( &segment{
+			elements: make( []interface{}, segmentSize ),
+		} )
+	}
+	q.initialSlots = q.slots
+
+	return q
+}
+
+// capacity returns the number of elements the queue can hold
+// without allocating more storage.
+func ( q *simpleQueue ) capacity() int {
+	return q.slots
+}
+
+// count returns the number of elements in the queue.
+func ( q *simpleQueue ) count() int {
+	return q.length
+}
+
+// newSegment takes a segment from the free list,
+// or allocates a new segment if the free list is empty.
+// A new segment can hold twice as many elements
+// as all other segments together,
+// so the capacity triples and a burst needs few allocations,
+// but it holds at least segmentSize and at most maxGrownSegmentSize elements.
+// In a bounded queue, it holds no more elements than still fit.
+func ( q *simpleQueue ) newSegment() *segment {
+	seg := q.free
+	if seg == nil {
+		size := 2 * q.slots
+		if size < q.segmentSize {
+			size = q.segmentSize
+		} else if size > maxGrownSegmentSize {
+			size = maxGrownSegmentSize
+		}
+		if ( q.limit > q.length ) && ( size > q.limit - q.length ) {
+			size = q.limit - q.length
+		}
+		q.slots += size
+		return &segment{
+			elements: make( []interface{}, size ),
 		}
-	} else {
-		q.buf1[q.end] = x
 	}
+	q.free = seg.next
+	seg.next = nil
+	q.spareSlots -= len( seg.elements )
+
+	return seg
+}
+
+// recycle puts an empty segment on the free list.
+func ( q *simpleQueue ) recycle( seg *segment ) {
+	seg.next = q.free
+	q.free = seg
+	q.spareSlots += len( seg.elements )
+}
+
+// shrink releases spare segments in excess of the shrink ratio.
+// Once the queue is empty, the segment in use may be larger
+// than the initial capacity,
+// so the queue starts over with its initial capacity.
+// The shrink ratio must not be negative.
+func ( q *simpleQueue ) shrink() {
+	for ( q.free != nil ) && ( q.slots - len( q.free.elements ) >= q.initialSlots ) && ( float64( q.spareSlots ) > q.shrinkRatio * float64( q.length ) ) {
+		seg := q.free
+		q.free = seg.next
+		seg.next = nil
+		q.spareSlots -= len( seg.elements )
+		q.slots -= len( seg.elements )
+	}
+	if ( q.length == 0 ) && ( q.slots > q.initialSlots ) {
+		q.restart()
+	}
+}
+
+// restart starts over with a new queue of the initial capacity,
+// so all other segments are released.
+func ( q *simpleQueue ) restart() {
+	limit := q.limit
+	*q = *newSimpleQueue( q.initialSlots, q.segmentSize, q.shrinkRatio )
+	q.limit = limit
+}
+
+// tryEnqueue enqueues x unless the queue is full.
+// The return value indicates whether x has been enqueued.
+func ( q *simpleQueue ) tryEnqueue( x interface{} ) bool {
+	if ( q.limit > 0 ) && ( q.length >= q.limit ) {
+		return false
+	}
+	q.push( x )
+
+	return true
+}
+
+func ( q *simpleQueue ) enqueue( x interface{} ) {
+	if !q.tryEnqueue( x ) {
+		panic( ErrFull )
+	}
+}
+
+// tryEnqueueBatch checks the limit once
+// and enqueues as many elements of xs as fit.
+func ( q *simpleQueue ) tryEnqueueBatch( xs []interface{} ) int {
+	n := len( xs )
+	if ( q.limit > 0 ) && ( n > q.limit - q.length ) {
+		n = q.limit - q.length
+	}
+	for _, x := range xs[:n] {
+		q.push( x )
+	}
+
+	return n
+}
+
+// push appends x to the queue regardless of any limit.
+func ( q *simpleQueue ) push( x interface{} ) {
+	if q.end == len( q.tail.elements ) {
+		seg := q.newSegment()
+		q.tail.next = seg
+		q.tail = seg
+		q.end = 0
+	}
+	q.tail.elements[q.end] = x
 	q.end++
+	q.length++
 }
 
 func ( q *simpleQueue ) dequeue() ( x interface{}, ok bool ) {
-	if q.start == q.end {
+	if q.length == 0 {
 		ok = false
 		return
 	}
-	x = q.buf1[q.start]
-	q.buf1[q.start] = nil
+	x = q.head.elements[q.start]
+	q.head.elements[q.start] = nil
 	ok = true
 	q.start++
-	if q.start == len( q.buf1 ) {
-		q.start -= len( q.buf1 )
-		q.end -= len( q.buf1 )
-		q.buf1, q.buf2 = q.buf2, q.buf1
+	q.length--
+	if q.length == 0 {
+		// head == tail, start over at the beginning of the segment
+		q.start = 0
+		q.end = 0
+	} else if q.start == len( q.head.elements ) {
+		seg := q.head
+		q.head = seg.next
+		q.start = 0
+		seg.next = nil
+		q.recycle( seg )
+	}
+	if q.shrinkRatio >= 0 {
+		q.shrink()
 	}
 
 	return
 }
 
-// simpleQueueFactory implements factory for simpleQueue
-type simpleQueueFactory struct {
-	dbFactory
-	sq *simpleQueue
+func ( q *simpleQueue ) peek() ( x interface{}, ok bool ) {
+	if q.length == 0 {
+		ok = false
+		return
+	}
+
+	return q.head.elements[q.start], true
 }
 
-func newSimpleQueue( capacityPerBuffer int ) *simpleQueue {
-	return &simpleQueue{
-		buf1: make( []interface{}, capacityPerBuffer ),
-		buf2: make( []interface{}, capacityPerBuffer ),
-		start: 0,
-		end: 0,
+func ( q *simpleQueue ) snapshot() []interface{} {
+	xs := make( []interface{}, 0, q.length )
+	start := q.start
+	for seg := q.head; len( xs ) < q.length; seg = seg.next {
+		end := len( seg.elements )
+		if seg == q.tail {
+			end = q.end
+		}
+		xs = append( xs, seg.elements[start:end]... )
+		start = 0
+	}
+
+	return xs
+}
+
+func ( q *simpleQueue ) drain() []interface{} {
+	xs := make( []interface{}, q.length )
+	for i := range xs {
+		xs[i], _ = q.dequeue()
 	}
+
+	return xs
+}
+
+// clear restarts the queue (see restart).
+func ( q *simpleQueue ) clear() int {
+	n := q.length
+	q.restart()
+
+	return n
+}
+
+// simpleQueueFactory implements factory for simpleQueue
+type simpleQueueFactory struct {
+	segFactory
+	limit int
+	overflow OverflowPolicy
+	sq *simpleQueue
+	// q is sq, wrapped in an overflowQueue if sq is bounded.
+	q interfaceQueue
 }
 
 func ( sqf *simpleQueueFactory ) prepare() {
-	sqf.sq = newSimpleQueue( sqf.capacityPerBuffer )
+	sqf.sq = newSimpleQueue( sqf.initialCapacity, sqf.segmentSize, sqf.shrinkRatio )
+	sqf.sq.limit = sqf.limit
+	if sqf.limit > 0 {
+		sqf.q = newOverflowQueue( sqf.sq, sqf.overflow )
+	} else {
+		sqf.q = sqf.sq
+	}
 }
 
 func ( sqf *simpleQueueFactory ) commit() {
@@ -88,20 +328,36 @@ func ( sqf *simpleQueueFactory ) commit() {
 }
 
 func ( sqf *simpleQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
-	return makeEnqueue( sqf.sq, methodType )
+	return makeEnqueue( sqf.q, methodType )
 }
 
 func( sqf *simpleQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
-	return makeDequeue( sqf.sq, methodType )
+	return makeDequeue( sqf.q, methodType )
+}
+
+func ( sqf *simpleQueueFactory ) queue() interfaceQueue {
+	return sqf.q
 }
 
 func ( sqf *simpleQueueFactory ) reset() {
 	sqf.sq = nil
+	sqf.q = nil
 }
 
 func newSimpleQueueFactory( initialCapacity int ) factory {
+	return newBoundedSimpleQueueFactory( initialCapacity, 0, OverflowReject, DefaultShrinkRatio )
+}
+
+// newBoundedSimpleQueueFactory creates a factory for simple queues
+// holding at most limit elements.
+// A limit of 0 means the queue is unbounded.
+// The overflow policy must not be OverflowBlock.
+func newBoundedSimpleQueueFactory( initialCapacity, limit int, overflow OverflowPolicy, shrinkRatio float64 ) factory {
 	return &simpleQueueFactory{
-		dbFactory: *newDbFactory( initialCapacity ),
+		segFactory: *newSegFactory( initialCapacity, limit, shrinkRatio ),
+		limit: limit,
+		overflow: overflow,
 		sq: nil,
+		q: nil,
 	}
 }
@@ -25,14 +25,113 @@ package queue
 import(
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestSimpleCapacity( t *testing.T ) {
 	for i := -3; i < 4; i++ {
 		f := newSimpleQueueFactory( i ).( *simpleQueueFactory )
-		if f.capacityPerBuffer < 1 {
-			t.Errorf( "Too low buffer capacity: %d (requested: %d)", f.capacityPerBuffer, i )
+		if f.segmentSize < 1 {
+			t.Errorf( "Too low segment size: %d (requested capacity: %d)", f.segmentSize, i )
 		}
+		if f.initialCapacity < 1 {
+			t.Errorf( "Too low initial capacity: %d (requested capacity: %d)", f.initialCapacity, i )
+		}
+	}
+	f := newSimpleQueueFactory( 10 * maxSegmentSize + 1 ).( *simpleQueueFactory )
+	f.prepare()
+	if f.sq.capacity() < 10 * maxSegmentSize + 1 {
+		t.Errorf( "Initial capacity %d too low", f.sq.capacity() )
+	}
+	f.reset()
+	// A small initial capacity does not allocate a full segment.
+	f = newSimpleQueueFactory( DefaultInitialCapacity ).( *simpleQueueFactory )
+	f.prepare()
+	if f.sq.capacity() != DefaultInitialCapacity {
+		t.Errorf( "Capacity %d differs from initial capacity %d", f.sq.capacity(), DefaultInitialCapacity )
+	}
+	f.reset()
+}
+
+func TestSimpleSegments( t *testing.T ) {
+	const segmentSize = 4
+	q := newSimpleQueue( segmentSize, segmentSize, -1 )
+	// Fill several segments, then drain them,
+	// interleaved with a few operations to shift the start position.
+	for round := 0; round < 3; round++ {
+		q.enqueue( -1 )
+		q.dequeue()
+		for i := 0; i < 5 * segmentSize; i++ {
+			q.enqueue( i )
+		}
+		if q.length != 5 * segmentSize {
+			t.Errorf( "Bad length %d, expected %d", q.length, 5 * segmentSize )
+		}
+		for i := 0; i < 5 * segmentSize; i++ {
+			x, ok := q.dequeue()
+			if !ok || ( x != i ) {
+				t.Errorf( "Dequeue returned %v, %v instead of %d, true", x, ok, i )
+			}
+		}
+		if x, ok := q.dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue: %v", x )
+		}
+		if q.head != q.tail {
+			t.Error( "Empty queue spans several segments" )
+		}
+	}
+	// Emptied segments must have been recycled
+	spare := 0
+	for seg := q.free; seg != nil; seg = seg.next {
+		for _, x := range seg.elements {
+			if x != nil {
+				t.Errorf( "Recycled segment still references %v", x )
+			}
+		}
+		spare += len( seg.elements )
+	}
+	if spare != q.spareSlots {
+		t.Errorf( "Free list holds %d elements, expected %d", spare, q.spareSlots )
+	}
+	if spare + len( q.head.elements ) != q.capacity() {
+		t.Errorf( "Only %d of %d elements recycled", spare, q.capacity() - len( q.head.elements ) )
+	}
+	// Refilling must reuse recycled segments
+	capacity := q.capacity()
+	for i := 0; i < 5 * segmentSize; i++ {
+		q.enqueue( i )
+	}
+	if q.capacity() != capacity {
+		t.Errorf( "Refilling grew capacity from %d to %d despite recycled segments", capacity, q.capacity() )
+	}
+}
+
+func TestSimpleGrowth( t *testing.T ) {
+	const n = 4 * maxGrownSegmentSize
+	q := newSimpleQueue( DefaultInitialCapacity, maxSegmentSize, -1 )
+	for i := 0; i < n; i++ {
+		q.enqueue( i )
+	}
+	// Segments double the capacity up to maxGrownSegmentSize.
+	segments := 0
+	for seg := q.head; seg != nil; seg = seg.next {
+		if len( seg.elements ) > maxGrownSegmentSize {
+			t.Errorf( "Segment of size %d exceeds %d", len( seg.elements ), maxGrownSegmentSize )
+		}
+		segments++
+	}
+	if segments > 16 {
+		t.Errorf( "%d elements take %d segments", n, segments )
+	}
+	// Segments of bounded queues never exceed the limit.
+	const limit = 1000
+	q = newSimpleQueue( 1, maxSegmentSize, -1 )
+	q.limit = limit
+	for i := 0; i < limit; i++ {
+		q.enqueue( i )
+	}
+	if q.capacity() != limit {
+		t.Errorf( "Capacity %d of full bounded queue differs from limit %d", q.capacity(), limit )
 	}
 }
 
@@ -84,3 +183,308 @@ func TestSimpleQueue( t *testing.T ) {
 		}
 	}
 }
+
+func TestBoundedSimpleQueue( t *testing.T ) {
+	const limit = 5
+	f := newBoundedSimpleQueueFactory( 100, limit, OverflowReject, DefaultShrinkRatio )
+	if f.( *simpleQueueFactory ).initialCapacity > limit {
+		t.Errorf( "Initial capacity %d exceeds limit %d", f.( *simpleQueueFactory ).initialCapacity, limit )
+	}
+	f.prepare()
+	var enqueue func( int )
+	var dequeue func() ( int, bool )
+	enqueue = f.makeEnqueue( reflect.TypeOf( enqueue ) ).Interface().( func( int ) )
+	dequeue = f.makeDequeue( reflect.TypeOf( dequeue ) ).Interface().( func() ( int, bool ) )
+	f.commit()
+	f.reset()
+	for round := 0; round < 3; round++ {
+		for i := 0; i < limit; i++ {
+			enqueue( i )
+		}
+		func() {
+			defer func() {
+				if r := recover(); r != ErrFull {
+					t.Errorf( "Enqueue on full queue did not panic with ErrFull: %v", r )
+				}
+			}()
+			enqueue( limit )
+		}()
+		for i := 0; i < limit; i++ {
+			x, ok := dequeue()
+			if !ok {
+				t.Error( "Dequeue fails on non-empty queue" )
+			}
+			if x != i {
+				t.Errorf( "Dequeue returned wrong value: %d instead of %d", x, i )
+			}
+		}
+		if x, ok := dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue: %d", x )
+		}
+	}
+}
+
+func TestSimpleShrink( t *testing.T ) {
+	const segmentSize = 4
+	const burst = 100 * segmentSize
+	// Spare capacity up to the length of the queue
+	q := newSimpleQueue( 2 * segmentSize, segmentSize, 1 )
+	for i := 0; i < burst; i++ {
+		q.enqueue( i )
+	}
+	if q.capacity() < burst {
+		t.Errorf( "Capacity %d too low for %d elements", q.capacity(), burst )
+	}
+	for i := 0; i < burst - 1; i++ {
+		q.dequeue()
+	}
+	if q.spareSlots > 1 {
+		t.Errorf( "Spare capacity %d did not drop after drain", q.spareSlots )
+	}
+	q.dequeue()
+	if q.capacity() != 2 * segmentSize {
+		t.Errorf( "Capacity %d of empty queue differs from initial capacity %d", q.capacity(), 2 * segmentSize )
+	}
+	// Half drained queue keeps spare capacity according to ratio
+	q = newSimpleQueue( segmentSize, segmentSize, 0.5 )
+	for i := 0; i < burst; i++ {
+		q.enqueue( i )
+	}
+	for i := 0; i < burst / 2; i++ {
+		q.dequeue()
+	}
+	if float64( q.spareSlots ) > 0.5 * float64( q.length ) {
+		t.Errorf( "Spare capacity %d exceeds half of length %d", q.spareSlots, q.length )
+	}
+	// Negative ratio never shrinks
+	q = newSimpleQueue( segmentSize, segmentSize, -1 )
+	for i := 0; i < burst; i++ {
+		q.enqueue( i )
+	}
+	for i := 0; i < burst; i++ {
+		q.dequeue()
+	}
+	if q.capacity() < burst {
+		t.Errorf( "Capacity %d dropped despite negative shrink ratio", q.capacity() )
+	}
+}
+
+func TestLockedShrink( t *testing.T ) {
+	const burst = 100 * maxSegmentSize
+	f := newLockedQueueFactory( 0, 1 )
+	f.prepare()
+	lq := f.queue().( *lockedQueue )
+	f.commit()
+	f.reset()
+	for i := 0; i < burst; i++ {
+		lq.enqueue( i )
+	}
+	for i := 0; i < burst; i++ {
+		lq.dequeue()
+	}
+	if lq.capacity() > maxSegmentSize {
+		t.Errorf( "Capacity %d did not drop after drain", lq.capacity() )
+	}
+	// By default, the storage is kept for the next burst.
+	f = newLockedQueueFactory( 0, DefaultShrinkRatio )
+	f.prepare()
+	lq = f.queue().( *lockedQueue )
+	f.commit()
+	f.reset()
+	for i := 0; i < burst; i++ {
+		lq.enqueue( i )
+	}
+	for i := 0; i < burst; i++ {
+		lq.dequeue()
+	}
+	if lq.capacity() < burst {
+		t.Errorf( "Capacity %d dropped with default shrink ratio", lq.capacity() )
+	}
+}
+
+// slowEnqueue is the latency above which
+// an enqueue operation is considered slow in benchmarks.
+const slowEnqueue = 100 * time.Microsecond
+
+// fillAndDrain enqueues count elements into q and then drains it.
+// It returns the number of slow enqueue operations.
+func fillAndDrain( q interfaceQueue, count int ) ( slow int ) {
+	var x interface{} = q
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		q.enqueue( x )
+		if time.Since( start ) > slowEnqueue {
+			slow++
+		}
+	}
+	for i := 0; i < count; i++ {
+		q.dequeue()
+	}
+
+	return
+}
+
+// benchmarkBursts repeatedly enqueues a burst of elements into the same
+// queue and then drains it.
+// The queue is accessed directly to keep the overhead of
+// the reflection bridge out of the measurements.
+// Besides time and allocations,
+// it reports the number of slow enqueue operations per burst.
+func benchmarkBursts( b *testing.B, f factory, burst int ) {
+	f.prepare()
+	q := f.queue()
+	f.commit()
+	f.reset()
+	slow := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		slow += fillAndDrain( q, burst )
+	}
+	b.ReportMetric( float64( slow ) / float64( b.N ), "slow/op" )
+}
+
+// benchmarkGrowth is like benchmarkBursts,
+// except that each burst goes into a new queue.
+// This exposes the cost of growing the queue storage.
+func benchmarkGrowth( b *testing.B, f factory, burst int ) {
+	slow := 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		f.prepare()
+		q := f.queue()
+		f.commit()
+		f.reset()
+		slow += fillAndDrain( q, burst )
+	}
+	b.ReportMetric( float64( slow ) / float64( b.N ), "slow/op" )
+}
+
+// benchmarkSteady alternately enqueues and dequeues
+// while the queue holds a small backlog.
+func benchmarkSteady( b *testing.B, f factory ) {
+	f.prepare()
+	q := f.queue()
+	f.commit()
+	f.reset()
+	var x interface{} = b
+	for i := 0; i < 10; i++ {
+		q.enqueue( x )
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		q.enqueue( x )
+		q.dequeue()
+	}
+}
+
+func BenchmarkSimpleQueueSteady( b *testing.B ) {
+	benchmarkSteady( b, newSimpleQueueFactory( DefaultInitialCapacity ) )
+}
+
+func BenchmarkSimpleQueueBurst( b *testing.B ) {
+	benchmarkBursts( b, newSimpleQueueFactory( DefaultInitialCapacity ), 100000 )
+}
+
+func BenchmarkSimpleQueueGrowth( b *testing.B ) {
+	benchmarkGrowth( b, newSimpleQueueFactory( DefaultInitialCapacity ), 100000 )
+}
+
+// doubleBufferQueue is the double-buffered queue
+// which simpleQueue replaced.
+// It is kept as a baseline for the benchmarks.
+type doubleBufferQueue struct {
+	buf1 []interface{}
+	buf2 []interface{}
+	start, end int
+}
+
+func ( q *doubleBufferQueue ) enqueue( x interface{} ) {
+	if q.end >= len( q.buf1 ) {
+		if q.end >= len( q.buf1 ) + len( q.buf2 ) {
+			q.buf2 = append( q.buf2, x )
+		} else {
+			q.buf2[q.end - len( q.buf1 )] = x
+		}
+	} else {
+		q.buf1[q.end] = x
+	}
+	q.end++
+}
+
+func ( q *doubleBufferQueue ) dequeue() ( x interface{}, ok bool ) {
+	if q.start == q.end {
+		ok = false
+		return
+	}
+	x = q.buf1[q.start]
+	q.buf1[q.start] = nil
+	ok = true
+	q.start++
+	if q.start == len( q.buf1 ) {
+		q.start -= len( q.buf1 )
+		q.end -= len( q.buf1 )
+		q.buf1, q.buf2 = q.buf2, q.buf1
+	}
+
+	return
+}
+
+// doubleBufferQueueFactory implements factory for doubleBufferQueue
+type doubleBufferQueueFactory struct {
+	capacityPerBuffer int
+	dq *doubleBufferQueue
+}
+
+func ( dqf *doubleBufferQueueFactory ) prepare() {
+	dqf.dq = &doubleBufferQueue{
+		buf1: make( []interface{}, dqf.capacityPerBuffer ),
+		buf2: make( []interface{}, dqf.capacityPerBuffer ),
+	}
+}
+
+func ( dqf *doubleBufferQueueFactory ) commit() {
+	// empty
+}
+
+func ( dqf *doubleBufferQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( dqf.dq, methodType )
+}
+
+func ( dqf *doubleBufferQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( dqf.dq, methodType )
+}
+
+func ( dqf *doubleBufferQueueFactory ) queue() interfaceQueue {
+	return dqf.dq
+}
+
+func ( dqf *doubleBufferQueueFactory ) reset() {
+	dqf.dq = nil
+}
+
+func newDoubleBufferQueueFactory( initialCapacity int ) factory {
+	capacityPerBuffer := initialCapacity / 2
+	if capacityPerBuffer < 1 {
+		capacityPerBuffer = 1
+	}
+
+	return &doubleBufferQueueFactory{
+		capacityPerBuffer: capacityPerBuffer,
+		dq: nil,
+	}
+}
+
+func BenchmarkDoubleBufferQueueSteady( b *testing.B ) {
+	benchmarkSteady( b, newDoubleBufferQueueFactory( DefaultInitialCapacity ) )
+}
+
+func BenchmarkDoubleBufferQueueBurst( b *testing.B ) {
+	benchmarkBursts( b, newDoubleBufferQueueFactory( DefaultInitialCapacity ), 100000 )
+}
+
+func BenchmarkDoubleBufferQueueGrowth( b *testing.B ) {
+	benchmarkGrowth( b, newDoubleBufferQueueFactory( DefaultInitialCapacity ), 100000 )
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync"
+	"testing"
+)
+
+// sizeTestQueue is used to test size reports.
+type sizeTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Len func() int `queue:"len"`
+	Cap func() int `queue:"cap"`
+	IsEmpty func() bool `queue:"isEmpty"`
+	IsFull func() bool `queue:"isFull"`
+}
+
+func TestSize( t *testing.T ) {
+	const n = 100
+	for _, config := range allConfigs( 100 ) {
+		var q sizeTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		bounded := ( config.Flags & FBounded ) != 0
+		if !q.IsEmpty() || ( q.Len() != 0 ) {
+			t.Errorf( "New queue not empty with flags %x: length %d", config.Flags, q.Len() )
+		}
+		for i := 0; i < n; i++ {
+			if q.Len() != i {
+				t.Errorf( "Length %d, expected %d with flags %x", q.Len(), i, config.Flags )
+			}
+			if q.IsFull() {
+				t.Errorf( "Queue full at length %d with flags %x", i, config.Flags )
+			}
+			q.Enqueue( i )
+			if q.IsEmpty() {
+				t.Errorf( "Queue empty after enqueue with flags %x", config.Flags )
+			}
+			if q.Cap() < q.Len() {
+				t.Errorf( "Capacity %d below length %d with flags %x", q.Cap(), q.Len(), config.Flags )
+			}
+		}
+		if bounded {
+			if q.Cap() != n {
+				t.Errorf( "Capacity %d differs from limit %d with flags %x", q.Cap(), n, config.Flags )
+			}
+			if !q.IsFull() {
+				t.Errorf( "Queue not full at limit with flags %x", config.Flags )
+			}
+		} else if q.IsFull() {
+			t.Errorf( "Unbounded queue full with flags %x", config.Flags )
+		}
+		for i := n; i > 0; i-- {
+			if q.Len() != i {
+				t.Errorf( "Length %d, expected %d with flags %x", q.Len(), i, config.Flags )
+			}
+			q.Dequeue()
+		}
+		if !q.IsEmpty() || ( q.Len() != 0 ) {
+			t.Errorf( "Drained queue not empty with flags %x: length %d", config.Flags, q.Len() )
+		}
+		q.Dequeue()
+		if q.Len() != 0 {
+			t.Errorf( "Failed dequeue changes length to %d with flags %x", q.Len(), config.Flags )
+		}
+	}
+}
+
+func TestSizeParallel( t *testing.T ) {
+	const writers = 4
+	const iterations = 5000
+	for _, config := range filterConfigs( allConfigs( 100 ), multiReaderConfig, unboundedConfig ) {
+		var q sizeTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add( 2 )
+			go func() {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					q.Enqueue( i )
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					if n := q.Len(); ( n < 0 ) || ( n > writers * iterations ) {
+						t.Errorf( "Bogus length %d with flags %x", n, config.Flags )
+						return
+					}
+					q.Dequeue()
+				}
+			}()
+		}
+		wg.Wait()
+		n := q.Len()
+		for {
+			if _, ok := q.Dequeue(); !ok {
+				break
+			}
+			n--
+		}
+		if n != 0 {
+			t.Errorf( "Length off by %d after parallel use with flags %x", n, config.Flags )
+		}
+	}
+}
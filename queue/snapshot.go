@@ -0,0 +1,59 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync/atomic"
+)
+
+// asSnapshotQueue finds the queue supporting snapshots
+// among q and the queues wrapped by q.
+// If there is no such queue, nil is returned.
+func asSnapshotQueue( q interfaceQueue ) snapshotQueue {
+	for {
+		switch qq := q.( type ) {
+		case snapshotQueue:
+			return qq
+		case *implementationQueue:
+			if snapshotter, ok := qq.impl.( Snapshotter ); ok {
+				return implementationSnapshotter{ snapshotter }
+			}
+			return nil
+		case wrapperQueue:
+			q = qq.unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// snapshotNodes returns the values of the nodes after the dummy node head
+// up to the first node without a successor.
+func snapshotNodes( head *listNode ) []interface{} {
+	var xs []interface{}
+	for node := ( *listNode )( atomic.LoadPointer( &head.next ) ); node != nil; node = ( *listNode )( atomic.LoadPointer( &node.next ) ) {
+		xs = append( xs, node.value )
+	}
+
+	return xs
+}
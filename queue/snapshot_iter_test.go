@@ -0,0 +1,73 @@
+//go:build go1.23
+// +build go1.23
+
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"iter"
+	"testing"
+)
+
+// iterTestQueue is used to test iteration with range-over-func.
+type iterTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	All iter.Seq[int] `queue:"all"`
+	Consume iter.Seq[int] `queue:"consume"`
+}
+
+func TestAllRange( t *testing.T ) {
+	const n = 10
+	var q iterTestQueue
+	if err := Make( &q, nil ); err != nil {
+		t.Fatal( err )
+	}
+	for i := 0; i < n; i++ {
+		q.Enqueue( i )
+	}
+	next := 0
+	for x := range q.All {
+		if x != next {
+			t.Errorf( "Iteration yields %d instead of %d", x, next )
+		}
+		next++
+	}
+	if next != n {
+		t.Errorf( "Iteration stops at %d instead of %d", next, n )
+	}
+	next = 0
+	for x := range q.Consume {
+		if x != next {
+			t.Errorf( "Consuming iteration yields %d instead of %d", x, next )
+		}
+		next++
+		if next == n / 2 {
+			break
+		}
+	}
+	if x, ok := q.Dequeue(); !ok || ( x != n / 2 ) {
+		t.Errorf( "Dequeue after consuming iteration returned %d, %t", x, ok )
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// snapshotTestQueue is used to test iteration.
+type snapshotTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Len func() int `queue:"len"`
+	All func( func( int ) bool ) `queue:"all"`
+	Consume func( func( int ) bool ) `queue:"consume"`
+	Close func() `queue:"close"`
+}
+
+func TestAll( t *testing.T ) {
+	const n = 50
+	for _, config := range allConfigs( 100 ) {
+		var q snapshotTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		q.All( func( int ) bool {
+			t.Errorf( "Iteration over empty queue yields elements with flags %x", config.Flags )
+			return true
+		} )
+		for i := 0; i < n; i++ {
+			q.Enqueue( i )
+		}
+		q.Dequeue()
+		next := 1
+		q.All( func( x int ) bool {
+			if x != next {
+				t.Errorf( "Iteration yields %d instead of %d with flags %x", x, next, config.Flags )
+			}
+			next++
+			return true
+		} )
+		if next != n {
+			t.Errorf( "Iteration stops at %d instead of %d with flags %x", next, n, config.Flags )
+		}
+		if q.Len() != n - 1 {
+			t.Errorf( "Iteration changes length to %d with flags %x", q.Len(), config.Flags )
+		}
+		q.All( func( x int ) bool {
+			if x != 1 {
+				t.Errorf( "Iteration yields %d instead of 1 with flags %x", x, config.Flags )
+			}
+			return false
+		} )
+	}
+}
+
+func TestConsume( t *testing.T ) {
+	const n = 50
+	for _, config := range allConfigs( 100 ) {
+		var q snapshotTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		for i := 0; i < n; i++ {
+			q.Enqueue( i )
+		}
+		next := 0
+		q.Consume( func( x int ) bool {
+			if x != next {
+				t.Errorf( "Consuming iteration yields %d instead of %d with flags %x", x, next, config.Flags )
+			}
+			next++
+			return next != n / 2
+		} )
+		if q.Len() != n - n / 2 {
+			t.Errorf( "Length %d after stopping consuming iteration with flags %x", q.Len(), config.Flags )
+		}
+		q.Close()
+		q.Consume( func( x int ) bool {
+			if x != next {
+				t.Errorf( "Consuming iteration yields %d instead of %d with flags %x", x, next, config.Flags )
+			}
+			next++
+			return true
+		} )
+		if next != n {
+			t.Errorf( "Consuming iteration stops at %d instead of %d with flags %x", next, n, config.Flags )
+		}
+		if q.Len() != 0 {
+			t.Errorf( "Length %d after consuming iteration with flags %x", q.Len(), config.Flags )
+		}
+	}
+}
+
+func TestAllParallel( t *testing.T ) {
+	const iterations = 20000
+	for _, config := range filterConfigs( allConfigs( 100 ), multiReaderConfig, unboundedConfig, strictOrderConfig ) {
+		var q snapshotTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		// dequeued is the number of elements dequeued so far.
+		var dequeued int64
+		var wg sync.WaitGroup
+		wg.Add( 2 )
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				q.Enqueue( i )
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64( &dequeued ) < iterations {
+				if _, ok := q.Dequeue(); ok {
+					atomic.AddInt64( &dequeued, 1 )
+				}
+			}
+		}()
+		for atomic.LoadInt64( &dequeued ) < iterations {
+			before := int( atomic.LoadInt64( &dequeued ) )
+			prev := -1
+			q.All( func( x int ) bool {
+				if ( prev >= 0 ) && ( x != prev + 1 ) {
+					t.Fatalf( "Snapshot not contiguous with flags %x: %d follows %d", config.Flags, x, prev )
+				}
+				if ( prev < 0 ) && ( x < before ) {
+					t.Fatalf( "Snapshot starts with element %d dequeued before with flags %x", x, config.Flags )
+				}
+				prev = x
+				return true
+			} )
+		}
+		wg.Wait()
+	}
+}
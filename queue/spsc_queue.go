@@ -0,0 +1,320 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// cacheLineSize is the assumed size of a CPU cache line.
+// It is used for padding to avoid false sharing.
+const cacheLineSize = 64
+
+// listNode is a node in the linked list of a lock-free queue.
+type listNode struct {
+	// next points to the next node (*listNode).
+	// It must only be accessed atomically.
+	next unsafe.Pointer
+	value interface{}
+}
+
+// spscSegment is a fixed-size chunk of storage for an spscQueue.
+type spscSegment struct {
+	elements []interface{}
+	// next is the segment following this one.
+	// The writer sets it before it publishes
+	// the first element of the next segment.
+	next *spscSegment
+}
+
+// spscQueue is a lock-free queue for a single reader and a single writer.
+// It is a ring buffer made of fixed-size segments:
+// the writer fills the segment at the tail and,
+// once it is full, links a new segment to it.
+// The reader empties the segment at the head
+// and hands emptied segments back to the writer,
+// so in the steady state, enqueueing does not allocate.
+// The head and tail indices count the elements dequeued and enqueued,
+// respectively.
+// The writer publishes elements by advancing tail,
+// and the reader takes elements up to tail.
+// Apart from these indices and the spare segment,
+// all fields are owned by either the reader or the writer.
+type spscQueue struct {
+	// head counts the dequeued elements.
+	// It is only modified by the reader and must only be accessed atomically.
+	head uint64
+	// headSeg is the segment holding the oldest element.
+	headSeg *spscSegment
+	// headIdx is the index of the oldest element in headSeg.
+	headIdx int
+	_ [cacheLineSize]byte
+	// tail counts the enqueued elements.
+	// It is only modified by the writer and must only be accessed atomically.
+	tail uint64
+	// tailSeg is the segment receiving the next element.
+	tailSeg *spscSegment
+	// tailIdx is the index of the next element in tailSeg.
+	tailIdx int
+	_ [cacheLineSize]byte
+	// spare is an emptied segment (*spscSegment) handed back by the reader,
+	// or nil.
+	// It must only be accessed atomically.
+	spare unsafe.Pointer
+	// slots is the number of elements all segments of the queue can hold,
+	// including the spare segment.
+	// It must only be accessed atomically.
+	slots int64
+}
+
+// newSpscQueue creates a new spsc queue.
+// The first segment holds initialCapacity elements,
+// up to maxSegmentSize.
+// Further segments hold maxSegmentSize elements.
+func newSpscQueue( initialCapacity int ) *spscQueue {
+	if initialCapacity < 1 {
+		initialCapacity = 1
+	} else if initialCapacity > maxSegmentSize {
+		initialCapacity = maxSegmentSize
+	}
+	seg := &spscSegment{
+		elements: make( []interface{}, initialCapacity ),
+	}
+
+	return &spscQueue{
+		headSeg: seg,
+		tailSeg: seg,
+		slots: int64( initialCapacity ),
+	}
+}
+
+// newSegment returns the spare segment,
+// or a new segment if there is none.
+// It is called by the writer.
+func ( q *spscQueue ) newSegment() *spscSegment {
+	if seg := ( *spscSegment )( atomic.SwapPointer( &q.spare, nil ) ); seg != nil {
+		return seg
+	}
+	atomic.AddInt64( &q.slots, maxSegmentSize )
+
+	return &spscSegment{
+		elements: make( []interface{}, maxSegmentSize ),
+	}
+}
+
+// recycle hands an emptied segment back to the writer.
+// If there already is a spare segment, seg is released instead.
+// It is called by the reader.
+func ( q *spscQueue ) recycle( seg *spscSegment ) {
+	seg.next = nil
+	if !atomic.CompareAndSwapPointer( &q.spare, nil, unsafe.Pointer( seg ) ) {
+		atomic.AddInt64( &q.slots, -int64( len( seg.elements ) ) )
+	}
+}
+
+// put stores x in the slot at the tail without publishing it.
+func ( q *spscQueue ) put( x interface{} ) {
+	if q.tailIdx == len( q.tailSeg.elements ) {
+		seg := q.newSegment()
+		q.tailSeg.next = seg
+		q.tailSeg = seg
+		q.tailIdx = 0
+	}
+	q.tailSeg.elements[q.tailIdx] = x
+	q.tailIdx++
+}
+
+// available returns the number of elements the reader can take.
+func ( q *spscQueue ) available() int {
+	return int( atomic.LoadUint64( &q.tail ) - atomic.LoadUint64( &q.head ) )
+}
+
+// take removes the element at the head without advancing head.
+// The element must be available.
+func ( q *spscQueue ) take() interface{} {
+	if q.headIdx == len( q.headSeg.elements ) {
+		seg := q.headSeg
+		q.headSeg = seg.next
+		q.headIdx = 0
+		q.recycle( seg )
+	}
+	x := q.headSeg.elements[q.headIdx]
+	q.headSeg.elements[q.headIdx] = nil
+	q.headIdx++
+
+	return x
+}
+
+func ( q *spscQueue ) enqueue( x interface{} ) {
+	q.put( x )
+	atomic.AddUint64( &q.tail, 1 )
+}
+
+func ( q *spscQueue ) dequeue() ( x interface{}, ok bool ) {
+	if q.available() == 0 {
+		ok = false
+		return
+	}
+	x = q.take()
+	ok = true
+	atomic.AddUint64( &q.head, 1 )
+
+	return
+}
+
+// enqueueBatch publishes all elements by advancing tail once.
+func ( q *spscQueue ) enqueueBatch( xs []interface{} ) {
+	for _, x := range xs {
+		q.put( x )
+	}
+	atomic.AddUint64( &q.tail, uint64( len( xs ) ) )
+}
+
+func ( q *spscQueue ) dequeueBatch( dst []interface{} ) int {
+	n := q.available()
+	if n > len( dst ) {
+		n = len( dst )
+	}
+	for i := 0; i < n; i++ {
+		dst[i] = q.take()
+	}
+	atomic.AddUint64( &q.head, uint64( n ) )
+
+	return n
+}
+
+// drain removes the elements
+// which are available at the time drain starts.
+func ( q *spscQueue ) drain() []interface{} {
+	xs := make( []interface{}, q.available() )
+	for i := range xs {
+		xs[i] = q.take()
+	}
+	atomic.AddUint64( &q.head, uint64( len( xs ) ) )
+
+	return xs
+}
+
+// clear works like drain,
+// but also releases the spare segment.
+func ( q *spscQueue ) clear() int {
+	n := q.available()
+	for i := 0; i < n; i++ {
+		q.take()
+	}
+	atomic.AddUint64( &q.head, uint64( n ) )
+	if seg := ( *spscSegment )( atomic.SwapPointer( &q.spare, nil ) ); seg != nil {
+		atomic.AddInt64( &q.slots, -int64( len( seg.elements ) ) )
+	}
+
+	return n
+}
+
+// count returns the number of elements in the queue.
+// The head index is read first,
+// and it never overtakes the tail index,
+// so the result is never negative.
+func ( q *spscQueue ) count() int {
+	head := atomic.LoadUint64( &q.head )
+	tail := atomic.LoadUint64( &q.tail )
+
+	return int( tail - head )
+}
+
+func ( q *spscQueue ) capacity() int {
+	return int( atomic.LoadInt64( &q.slots ) )
+}
+
+func ( q *spscQueue ) peek() ( x interface{}, ok bool ) {
+	if q.available() == 0 {
+		ok = false
+		return
+	}
+	if q.headIdx == len( q.headSeg.elements ) {
+		return q.headSeg.next.elements[0], true
+	}
+
+	return q.headSeg.elements[q.headIdx], true
+}
+
+// snapshot contains the elements
+// which are available at the time snapshot starts.
+// Only the reader removes elements,
+// so this is the content of the queue at that time.
+func ( q *spscQueue ) snapshot() []interface{} {
+	xs := make( []interface{}, q.available() )
+	seg := q.headSeg
+	idx := q.headIdx
+	for i := range xs {
+		if idx == len( seg.elements ) {
+			seg = seg.next
+			idx = 0
+		}
+		xs[i] = seg.elements[idx]
+		idx++
+	}
+
+	return xs
+}
+
+// spscQueueFactory implements factory for spscQueue
+type spscQueueFactory struct {
+	initialCapacity int
+	sq *spscQueue
+}
+
+func ( sqf *spscQueueFactory ) prepare() {
+	sqf.sq = newSpscQueue( sqf.initialCapacity )
+}
+
+func ( sqf *spscQueueFactory ) commit() {
+	// empty
+}
+
+func ( sqf *spscQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( sqf.sq, methodType )
+}
+
+func ( sqf *spscQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( sqf.sq, methodType )
+}
+
+func ( sqf *spscQueueFactory ) queue() interfaceQueue {
+	return sqf.sq
+}
+
+func ( sqf *spscQueueFactory ) reset() {
+	sqf.sq = nil
+}
+
+// newSpscQueueFactory creates a factory for lock-free queues
+// with a single reader and a single writer.
+// Values of initialCapacity too small or too large will be corrected.
+func newSpscQueueFactory( initialCapacity int ) factory {
+	return &spscQueueFactory{
+		initialCapacity: initialCapacity,
+		sq: nil,
+	}
+}
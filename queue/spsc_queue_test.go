@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestSpscQueue( t *testing.T ) {
+	f := newSpscQueueFactory( DefaultInitialCapacity )
+	f.prepare()
+	var enqueue func( int )
+	var dequeue func() ( int, bool )
+	enqueue = f.makeEnqueue( reflect.TypeOf( enqueue ) ).Interface().( func( int ) )
+	dequeue = f.makeDequeue( reflect.TypeOf( dequeue ) ).Interface().( func() ( int, bool ) )
+	f.commit()
+	f.reset()
+	// Empty dequeue check
+	x, ok := dequeue()
+	if ok {
+		t.Error( "Dequeue succeeds on empty queue" )
+	}
+	if x != 0 {
+		t.Errorf( "Failed dequeue does not return zero value: %d", x )
+	}
+	// Sequential queue order check
+	for i := 0; i < 100; i++ {
+		enqueue( i )
+	}
+	for i := 0; i < 100; i++ {
+		x, ok = dequeue()
+		if !ok {
+			t.Error( "Dequeue fails on non-empty queue" )
+		}
+		if x != i {
+			t.Errorf( "Dequeue returned wrong value: %d instead of %d", x, i )
+		}
+	}
+	// Parallel queue check
+	const iterations = 10000
+	var wg sync.WaitGroup
+	writer := func() {
+		defer wg.Done()
+		for i := 1; i <= iterations; i++ {
+			enqueue( i )
+		}
+	}
+	reader := func() {
+		defer wg.Done()
+		expected := 1
+		for expected <= iterations {
+			x, ok := dequeue()
+			if !ok {
+				runtime.Gosched()
+				continue
+			}
+			if x != expected {
+				t.Errorf( "Out of order dequeue: %d instead of %d", x, expected )
+				return
+			}
+			expected++
+		}
+		if x, ok := dequeue(); ok {
+			t.Errorf( "Spurious successful dequeue: %d", x )
+		}
+	}
+	wg.Add( 2 )
+	go writer()
+	go reader()
+	wg.Wait()
+}
+
+func TestSpscQueueRecycle( t *testing.T ) {
+	q := newSpscQueue( DefaultInitialCapacity )
+	var x interface{} = 42
+	// Warm up so that both segments in the steady state exist.
+	for i := 0; i < 2 * maxSegmentSize; i++ {
+		q.enqueue( x )
+		q.dequeue()
+	}
+	allocs := testing.AllocsPerRun( 10, func() {
+		for i := 0; i < 4 * maxSegmentSize; i++ {
+			q.enqueue( x )
+			q.dequeue()
+		}
+	} )
+	if allocs != 0 {
+		t.Errorf( "Steady state allocates %v times per run", allocs )
+	}
+	if q.capacity() > 2 * maxSegmentSize {
+		t.Errorf( "Capacity %d exceeds two segments in steady state", q.capacity() )
+	}
+}
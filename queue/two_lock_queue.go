@@ -0,0 +1,209 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// twoLockQueue is the two-lock concurrent queue by Michael and Scott.
+// Writers serialise on the tail lock and readers on the head lock,
+// so writers and readers do not contend with each other.
+// The node head points to is a dummy node.
+// When the queue is empty, head and tail point to the same node,
+// which is why the next pointers are accessed atomically.
+type twoLockQueue struct {
+	headLock sync.Mutex
+	head *listNode
+	// dequeued counts the dequeued elements.
+	// It must only be accessed atomically.
+	dequeued uint64
+	_ [cacheLineSize]byte
+	tailLock sync.Mutex
+	tail *listNode
+	// enqueued counts the enqueued elements.
+	// It must only be accessed atomically.
+	enqueued uint64
+}
+
+func newTwoLockQueue() *twoLockQueue {
+	dummy := &listNode{}
+	return &twoLockQueue{
+		head: dummy,
+		tail: dummy,
+	}
+}
+
+func ( q *twoLockQueue ) enqueue( x interface{} ) {
+	node := &listNode{
+		value: x,
+	}
+	q.tailLock.Lock()
+	defer q.tailLock.Unlock()
+	atomic.AddUint64( &q.enqueued, 1 )
+	atomic.StorePointer( &q.tail.next, unsafe.Pointer( node ) )
+	q.tail = node
+}
+
+func ( q *twoLockQueue ) dequeue() ( x interface{}, ok bool ) {
+	q.headLock.Lock()
+	defer q.headLock.Unlock()
+	next := ( *listNode )( atomic.LoadPointer( &q.head.next ) )
+	if next == nil {
+		ok = false
+		return
+	}
+	x = next.value
+	next.value = nil
+	ok = true
+	q.head = next
+	atomic.AddUint64( &q.dequeued, 1 )
+
+	return
+}
+
+// enqueueBatch links all nodes before taking the tail lock.
+func ( q *twoLockQueue ) enqueueBatch( xs []interface{} ) {
+	if len( xs ) == 0 {
+		return
+	}
+	first, last := newNodeChain( xs )
+	q.tailLock.Lock()
+	defer q.tailLock.Unlock()
+	atomic.AddUint64( &q.enqueued, uint64( len( xs ) ) )
+	atomic.StorePointer( &q.tail.next, unsafe.Pointer( first ) )
+	q.tail = last
+}
+
+func ( q *twoLockQueue ) dequeueBatch( dst []interface{} ) int {
+	q.headLock.Lock()
+	defer q.headLock.Unlock()
+	n := 0
+	for n < len( dst ) {
+		next := ( *listNode )( atomic.LoadPointer( &q.head.next ) )
+		if next == nil {
+			break
+		}
+		dst[n] = next.value
+		next.value = nil
+		q.head = next
+		n++
+	}
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+	return n
+}
+
+// drain works like mpscQueue.drain.
+func ( q *twoLockQueue ) drain() []interface{} {
+	q.headLock.Lock()
+	defer q.headLock.Unlock()
+	last, xs, n := drainNodes( q.head, true )
+	q.head = last
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+	return xs
+}
+
+func ( q *twoLockQueue ) clear() int {
+	q.headLock.Lock()
+	defer q.headLock.Unlock()
+	last, _, n := drainNodes( q.head, false )
+	q.head = last
+	atomic.AddUint64( &q.dequeued, uint64( n ) )
+
+	return n
+}
+
+// count works like mpscQueue.count.
+func ( q *twoLockQueue ) count() int {
+	dequeued := atomic.LoadUint64( &q.dequeued )
+	enqueued := atomic.LoadUint64( &q.enqueued )
+
+	return int( enqueued - dequeued )
+}
+
+func ( q *twoLockQueue ) capacity() int {
+	return q.count()
+}
+
+func ( q *twoLockQueue ) peek() ( x interface{}, ok bool ) {
+	q.headLock.Lock()
+	defer q.headLock.Unlock()
+	next := ( *listNode )( atomic.LoadPointer( &q.head.next ) )
+	if next == nil {
+		ok = false
+		return
+	}
+
+	return next.value, true
+}
+
+// snapshot holds the head lock,
+// so otherwise it works like mpscQueue.snapshot.
+func ( q *twoLockQueue ) snapshot() []interface{} {
+	q.headLock.Lock()
+	defer q.headLock.Unlock()
+	return snapshotNodes( q.head )
+}
+
+// twoLockQueueFactory implements factory for twoLockQueue
+type twoLockQueueFactory struct {
+	tq *twoLockQueue
+}
+
+func ( tqf *twoLockQueueFactory ) prepare() {
+	tqf.tq = newTwoLockQueue()
+}
+
+func ( tqf *twoLockQueueFactory ) commit() {
+	// empty
+}
+
+func ( tqf *twoLockQueueFactory ) makeEnqueue( methodType reflect.Type ) reflect.Value {
+	return makeEnqueue( tqf.tq, methodType )
+}
+
+func ( tqf *twoLockQueueFactory ) makeDequeue( methodType reflect.Type ) reflect.Value {
+	return makeDequeue( tqf.tq, methodType )
+}
+
+func ( tqf *twoLockQueueFactory ) queue() interfaceQueue {
+	return tqf.tq
+}
+
+func ( tqf *twoLockQueueFactory ) reset() {
+	tqf.tq = nil
+}
+
+// newTwoLockQueueFactory creates a factory for two-lock queues
+// with multiple readers and multiple writers.
+// Since the queue is a linked list, there is no initial capacity.
+func newTwoLockQueueFactory() factory {
+	return &twoLockQueueFactory{
+		tq: nil,
+	}
+}
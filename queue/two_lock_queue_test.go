@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTwoLockQueue( t *testing.T ) {
+	testMpmcQueue( t, newTwoLockQueueFactory() )
+	// Writers and readers take different locks,
+	// so either side makes progress while the other lock is held.
+	q := newTwoLockQueue()
+	q.enqueue( 0 )
+	q.headLock.Lock()
+	q.enqueue( 1 )
+	q.headLock.Unlock()
+	q.tailLock.Lock()
+	if x, ok := q.dequeue(); !ok || ( x != 0 ) {
+		t.Errorf( "Dequeue returned %v, %t instead of 0, true", x, ok )
+	}
+	q.tailLock.Unlock()
+	// The new dummy node must not keep the dequeued element alive.
+	if x, ok := q.dequeue(); !ok || ( x != 1 ) {
+		t.Errorf( "Dequeue returned %v, %t instead of 1, true", x, ok )
+	}
+	if q.head.value != nil {
+		t.Errorf( "Dummy node holds on to %v", q.head.value )
+	}
+	if ( q.head != q.tail ) || ( q.count() != 0 ) {
+		t.Errorf( "Empty queue has %d elements", q.count() )
+	}
+}
+
+// benchmarkContention runs the given number of writers and readers
+// concurrently on a queue made by f.
+// Each writer enqueues b.N elements,
+// and the readers dequeue all of them.
+func benchmarkContention( b *testing.B, f factory, writers, readers int ) {
+	f.prepare()
+	q := f.queue()
+	f.commit()
+	f.reset()
+	var x interface{} = b
+	total := int64( writers ) * int64( b.N )
+	var received int64
+	var wg sync.WaitGroup
+	b.ReportAllocs()
+	b.ResetTimer()
+	wg.Add( writers + readers )
+	for i := 0; i < writers; i++ {
+		go func() {
+			defer wg.Done()
+			for n := 0; n < b.N; n++ {
+				q.enqueue( x )
+			}
+		}()
+	}
+	for i := 0; i < readers; i++ {
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64( &received ) < total {
+				if _, ok := q.dequeue(); ok {
+					atomic.AddInt64( &received, 1 )
+				} else {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// BenchmarkContention compares the two-lock queue with the locked queue
+// for growing numbers of writers and readers.
+func BenchmarkContention( b *testing.B ) {
+	factories := []struct{
+		name string
+		newFactory func() factory
+	}{
+		{ "Locked", func() factory { return newLockedQueueFactory( DefaultInitialCapacity, DefaultShrinkRatio ) } },
+		{ "TwoLock", newTwoLockQueueFactory },
+	}
+	for _, n := range []int{ 1, 2, 4, 8, 16 } {
+		for _, f := range factories {
+			b.Run( f.name + "/" + strconv.Itoa( n ) + "x" + strconv.Itoa( n ), func( b *testing.B ) {
+				benchmarkContention( b, f.newFactory(), n, n )
+			} )
+		}
+	}
+}
@@ -22,6 +22,11 @@ SOFTWARE.
 
 package queue
 
+import(
+	"context"
+	"time"
+)
+
 // T is a placeholder for an actual queue element type.
 type T interface{}
 
@@ -33,11 +38,67 @@ type T interface{}
 // to the Make() function.
 type GenericQueue struct {
 	// Enqueue enqueues element x into the queue.
+	// If the queue is bounded and full, Enqueue panics with ErrFull.
+	// If the queue has been closed, Enqueue panics with ErrClosed.
+	// Instead of panicking, Enqueue can return these errors
+	// if you give it the signature func( x T ) error.
+	// If you give Enqueue the signature func( x T ) uint64,
+	// it returns the sequence number assigned to x.
+	// Sequence numbers start at 1 and increase by one with every element
+	// enqueued by any writer, so a gap in the sequence numbers seen by
+	// readers means that elements have been dropped or removed.
+	// In strictly ordered queues,
+	// the sequence numbers are in the same order as the elements.
+	// Assigning sequence numbers serialises the writers on one lock
+	// and the readers on another, even in queues which are lock-free
+	// otherwise, such as the default single-writer or single-reader queues.
+	// With sequence numbers, such queues therefore perform
+	// like a two-lock queue, and each of Enqueue and Dequeue takes a lock
+	// in addition to whatever the underlying queue needs.
+	// For the same reason, Make fails for queues configured as lock-free
+	// (see Config.LockFree).
+	// Relaxed order and priority queues do not dequeue elements
+	// in the order they were enqueued, so Make fails for them, too.
+	// Sequence numbers are used if Enqueue returns uint64,
+	// or if Dequeue returns a sequence number.
+	// Elements discarded with OverflowDropNewest get the sequence number 0.
 	// The tag is used by Make()
 	// to identify this as the enqueueing function.
 	// If you like, you can give this function a different name.
 	Enqueue func( x T ) `queue:"enqueue"`
 
+	// TryEnqueue attempts to enqueue element x into the queue
+	// without blocking.
+	// It returns false if the queue is full or has been closed.
+	// With OverflowDropOldest, x is always enqueued
+	// (unless the queue has been closed),
+	// and with OverflowDropNewest, it is discarded if the queue is full.
+	// TryEnqueue can take the place of Enqueue.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	TryEnqueue func( x T ) bool `queue:"tryEnqueue"`
+
+	// EnqueueWait enqueues element x into the queue,
+	// waiting while a bounded queue is full,
+	// regardless of the overflow policy.
+	// Waiting writers are served in the order in which they started waiting,
+	// so a busy writer cannot starve the others.
+	// Non-concurrent queues cannot wait,
+	// so for them, EnqueueWait panics with ErrFull if the queue is full.
+	// If the queue has been closed, or is closed while waiting,
+	// EnqueueWait panics with ErrClosed.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	EnqueueWait func( x T ) `queue:"enqueueWait"`
+
+	// EnqueueCtx works like EnqueueWait,
+	// but stops waiting when ctx is done,
+	// in which case ctx.Err() is returned.
+	// Instead of panicking, EnqueueCtx returns ErrFull or ErrClosed.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	EnqueueCtx func( ctx context.Context, x T ) error `queue:"enqueueCtx"`
+
 	// Dequeue attempts to dequeue an element from the queue.
 	// If successful, the dequeued element is returned as x
 	// and ok is true.
@@ -45,10 +106,259 @@ type GenericQueue struct {
 	// the queue was empty
 	// at the time the dequeueing operation was attempted,
 	// the value of x is the zero value of T and ok is false.
+	// If you give Dequeue the signature func() ( x T, err error ),
+	// err is nil on success,
+	// ErrClosed if the queue has been closed and no elements are left,
+	// and ErrEmpty otherwise.
+	// If you give Dequeue the signature func() ( x T, seq uint64, ok bool ),
+	// it also returns the sequence number of x (see Enqueue),
+	// or 0 if the queue was empty.
 	// The tag is used by Make()
 	// to identify this as the dequeueing function.
 	// If you like, you can give this function a different name.
 	Dequeue func()( x T, ok bool ) `queue:"dequeue"`
+
+	// Discarded returns the number of elements the queue has discarded
+	// so far due to its overflow policy (see Config.Overflow).
+	// Elements rejected with OverflowReject count as discarded.
+	// Unbounded queues never discard elements.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Discarded func() uint64 `queue:"discarded"`
+
+	// DequeueWait dequeues an element from the queue,
+	// waiting until one is available.
+	// Non-concurrent queues cannot wait,
+	// so for them, DequeueWait panics with ErrEmpty if the queue is empty.
+	// If the queue has been closed and no elements are left,
+	// DequeueWait panics with ErrClosed.
+	// If you give DequeueWait the signature func() ( x T, ok bool ),
+	// it returns the zero value of T and false instead,
+	// so you can consume all elements with
+	//  for x, ok := q.DequeueWait(); ok; x, ok = q.DequeueWait() { ... }
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	DequeueWait func() T `queue:"dequeueWait"`
+
+	// DequeueCtx dequeues an element from the queue,
+	// waiting until one is available or ctx is done.
+	// In the latter case, the zero value of T and ctx.Err() are returned.
+	// If the queue has been closed and no elements are left,
+	// ErrClosed is returned.
+	// An element is never removed from the queue without being returned,
+	// even if cancellation and enqueueing race each other.
+	// Non-concurrent queues cannot wait,
+	// so for them, DequeueCtx returns ErrEmpty if the queue is empty.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	DequeueCtx func( ctx context.Context ) ( x T, err error ) `queue:"dequeueCtx"`
+
+	// DequeueTimeout dequeues an element from the queue,
+	// waiting at most for the given duration until one is available.
+	// If successful, the dequeued element is returned as x
+	// and ok is true.
+	// Otherwise, the value of x is the zero value of T and ok is false.
+	// A non-positive timeout does not wait at all,
+	// and neither do non-concurrent queues.
+	// DequeueTimeout stops waiting when the queue is closed.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	DequeueTimeout func( timeout time.Duration ) ( x T, ok bool ) `queue:"dequeueTimeout"`
+
+	// Peek returns the element at the head of the queue
+	// without removing it.
+	// If the queue is empty, the value of x is the zero value of T
+	// and ok is false.
+	// Peek counts as a reader,
+	// so unless the queue is configured for multiple readers,
+	// Peek must not be called concurrently with dequeueing functions.
+	// With a single reader and strict FIFO order,
+	// the next dequeue returns the peeked element.
+	// With multiple readers,
+	// another reader may dequeue the peeked element first.
+	// With relaxed order (see Config.RelaxedOrder),
+	// elements enqueued concurrently may be dequeued before the peeked one.
+	// With OverflowDropOldest, a writer may discard the peeked element
+	// to make room for a new one,
+	// so the next dequeue may return a later element even with a single reader.
+	// Make picks an implementation which supports peeking.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Peek func() ( x T, ok bool ) `queue:"peek"`
+
+	// Len returns the number of elements in the queue.
+	// With concurrent access, the result is only a snapshot
+	// which may be outdated by the time Len returns.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Len func() int `queue:"len"`
+
+	// Cap returns the capacity limit of a bounded queue.
+	// For an unbounded queue, Cap returns the number of elements
+	// the queue can hold without allocating more storage.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Cap func() int `queue:"cap"`
+
+	// IsEmpty reports whether the queue is empty.
+	// Like Len, it only reports a snapshot.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	IsEmpty func() bool `queue:"isEmpty"`
+
+	// IsFull reports whether a bounded queue is at its capacity limit.
+	// Like Len, it only reports a snapshot.
+	// Unbounded queues are never full.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	IsFull func() bool `queue:"isFull"`
+
+	// EnqueueBatch enqueues the elements of xs in order.
+	// Many implementations enqueue the whole batch with a single lock
+	// acquisition or atomic operation,
+	// which is considerably cheaper than enqueueing one element at a time.
+	// You can also give EnqueueBatch the variadic signature
+	// func( xs ...T ).
+	// If enqueueing fails, EnqueueBatch panics like Enqueue,
+	// and elements already enqueued remain in the queue.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	EnqueueBatch func( xs []T ) `queue:"enqueueBatch"`
+
+	// DequeueBatch dequeues up to len( dst ) elements into dst
+	// and returns the number n of dequeued elements,
+	// which are stored in dst[:n].
+	// It does not wait for elements to arrive.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	DequeueBatch func( dst []T ) ( n int ) `queue:"dequeueBatch"`
+
+	// Drain atomically removes all elements from the queue
+	// and returns them in order.
+	// Drain counts as a reader, like Peek.
+	// Make picks an implementation which supports draining.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Drain func() []T `queue:"drain"`
+
+	// Clear atomically removes all elements from the queue
+	// and releases the memory they occupied,
+	// keeping only the initial capacity (see Config.InitialCapacity).
+	// Unlike creating a new queue with Make,
+	// Clear keeps all functions in the structure valid.
+	// Clear counts as a reader, like Peek.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Clear func() `queue:"clear"`
+
+	// All iterates over the elements of the queue in order
+	// without removing them.
+	// The iteration visits a snapshot of the queue,
+	// i. e., the content of the queue at a single point in time
+	// between the call to All and the first call to yield.
+	// Elements enqueued or dequeued later do not affect the iteration.
+	// With Go 1.23 or later, you can give All the type iter.Seq[T]
+	// and use it in a range loop:
+	//  for x := range q.All { ... }
+	// All counts as a reader, like Peek.
+	// Make picks an implementation which supports snapshots.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	All func( yield func( T ) bool ) `queue:"all"`
+
+	// Consume iterates over the elements of the queue,
+	// dequeueing each element before passing it to yield.
+	// The iteration stops when the queue is empty,
+	// in particular when it has been closed and no elements are left,
+	// or when yield returns false.
+	// Consume does not wait for elements to arrive.
+	// With Go 1.23 or later, you can give Consume the type iter.Seq[T].
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Consume func( yield func( T ) bool ) `queue:"consume"`
+
+	// WaitEmpty waits until all elements enqueued before the call
+	// have been consumed, or until ctx is done,
+	// in which case ctx.Err() is returned.
+	// Elements enqueued later do not hold up WaitEmpty.
+	// An element is consumed when it is dequeued,
+	// or, if the structure has a Done function,
+	// when it is marked done.
+	// Elements discarded due to the overflow policy are consumed right away.
+	// Elements are not tracked individually:
+	// WaitEmpty returns once as many elements have been consumed
+	// as had been enqueued when it was called,
+	// or once all elements have been consumed.
+	// In a FIFO queue without Done, this implies that every element
+	// enqueued before the call has been dequeued.
+	// Non-concurrent queues cannot wait,
+	// so for them, WaitEmpty returns ErrPending instead.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	WaitEmpty func( ctx context.Context ) error `queue:"waitEmpty"`
+
+	// Done marks a dequeued element as done, like sync.WaitGroup.Done.
+	// If the structure has a Done function,
+	// dequeued elements only count as consumed for WaitEmpty
+	// once they have been marked done.
+	// Each dequeued element must be marked done exactly once.
+	// Done panics with ErrDone if more elements are marked done
+	// than have been dequeued.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Done func() `queue:"done"`
+
+	// Ready returns a channel which is closed
+	// once the queue is not empty or has been closed,
+	// so that waiting for elements can be combined with other channels
+	// in a select statement.
+	// Afterwards, call Dequeue to obtain an element.
+	// With multiple readers, another reader may have been faster,
+	// in which case Dequeue fails and you should call Ready again.
+	// For non-concurrent queues, the channel is always closed,
+	// as no other goroutine can enqueue an element:
+	// Dequeue then tells right away whether an element is available.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Ready func() <-chan struct{} `queue:"ready"`
+
+	// NotFull returns a channel which is closed
+	// once a bounded queue is not full or has been closed.
+	// For unbounded and for non-concurrent queues,
+	// the channel is always closed.
+	// Like Ready, NotFull only indicates that enqueueing may succeed:
+	// with multiple writers, another writer may have been faster.
+	// Registered implementations (see Register) cannot report
+	// when they have space again,
+	// so Make does not choose them for concurrent bounded queues
+	// with this function.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	NotFull func() <-chan struct{} `queue:"notFull"`
+
+	// Less orders the elements of a priority queue.
+	// Unlike the other functions, Less is not set by Make.
+	// Instead, you set it before calling Make,
+	// which then creates a priority queue:
+	// Dequeue returns the minimum element with respect to Less
+	// instead of the oldest,
+	// and elements which compare equal are dequeued in FIFO order.
+	// Peek, All, and the other functions observe the same order.
+	// If Less is nil, the queue is ordered by the function given with
+	// Config.Priority, if any, and FIFO otherwise.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Less func( a, b T ) bool `queue:"less"`
+
+	// Close closes the queue.
+	// Afterwards, enqueueing fails with ErrClosed,
+	// while the elements left in the queue can still be dequeued.
+	// Goroutines waiting to dequeue from the empty queue,
+	// or to enqueue into a full queue with OverflowBlock, are woken up.
+	// Closing a closed queue has no effect.
+	// This function is optional.
+	// If you do not need it, you can remove it from your structure.
+	Close func() `queue:"close"`
 }
 
 // interfaceQueue is the minimal generic queue interface used internally.
@@ -56,3 +366,75 @@ type interfaceQueue interface {
 	enqueue( x interface{} )
 	dequeue() ( x interface{}, ok bool )
 }
+
+// wrapperQueue is the internal interface of queues
+// which add functionality to another queue.
+type wrapperQueue interface {
+	interfaceQueue
+
+	// unwrap returns the wrapped queue.
+	unwrap() interfaceQueue
+}
+
+// waitEnqueueQueue is the internal interface of queues
+// whose writers can wait while the queue is full.
+type waitEnqueueQueue interface {
+	// enqueueCtx enqueues x,
+	// waiting while the queue is full until ctx is done.
+	// Instead of panicking, enqueueCtx returns an error
+	// if x cannot be enqueued.
+	enqueueCtx( ctx context.Context, x interface{} ) error
+}
+
+// batchQueue is the internal interface of queues
+// which can enqueue or dequeue several elements at once
+// more efficiently than one at a time.
+type batchQueue interface {
+	// enqueueBatch enqueues the elements of xs in order.
+	enqueueBatch( xs []interface{} )
+
+	// dequeueBatch dequeues up to len( dst ) elements into dst
+	// and returns the number of dequeued elements.
+	dequeueBatch( dst []interface{} ) int
+}
+
+// drainQueue is the internal interface of queues
+// which can remove all elements at once.
+type drainQueue interface {
+	// drain removes all elements from the queue
+	// and returns them in order.
+	drain() []interface{}
+
+	// clear removes all elements from the queue
+	// and releases storage beyond the initial capacity.
+	// It returns the number of removed elements.
+	clear() int
+}
+
+// snapshotQueue is the internal interface of queues
+// which can list their elements without removing them.
+type snapshotQueue interface {
+	// snapshot returns the elements of the queue in order.
+	// The result is the content of the queue at a single point in time.
+	snapshot() []interface{}
+}
+
+// sizeQueue is the internal interface of queues
+// which can report their size.
+// Under concurrent access, the results are only snapshots.
+type sizeQueue interface {
+	// count returns the number of elements in the queue.
+	count() int
+
+	// capacity returns the number of elements the queue can hold
+	// without allocating more storage.
+	capacity() int
+}
+
+// peekQueue is the internal interface of queues supporting peek.
+type peekQueue interface {
+	// peek returns the element at the head of the queue
+	// without removing it.
+	// If the queue is empty, ok is false.
+	peek() ( x interface{}, ok bool )
+}
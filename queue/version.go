@@ -35,7 +35,8 @@ SOFTWARE.
 //
 // A configuration can be passed to the Make function,
 // choosing an implementation with specific characteristics for the queue.
-// For example, configurable parameters are initial queue buffer size or
+// For example, configurable parameters are initial queue buffer size,
+// an upper limit on the number of queue elements, or
 // whether the queue should be safe to access concurrently.
 // The default configuration yields a queue suitable for most uses.
 package queue
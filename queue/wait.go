@@ -0,0 +1,133 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"time"
+)
+
+// waitQueue wraps a concurrent queue
+// so that consumers can wait for elements to arrive.
+// If the wrapped queue is a closeQueue,
+// waiting consumers are also woken up when the queue is closed.
+type waitQueue struct {
+	queue interfaceQueue
+	// notEmpty is notified after each enqueue.
+	notEmpty signal
+	// done is closed when the queue is closed.
+	// It is nil if the queue cannot be closed.
+	done <-chan struct{}
+}
+
+func newWaitQueue( queue interfaceQueue ) *waitQueue {
+	q := &waitQueue{
+		queue: queue,
+	}
+	if cq, ok := queue.( *closeQueue ); ok {
+		q.done = cq.done
+		cq.onClose = append( cq.onClose, q.notEmpty.notify )
+	}
+
+	return q
+}
+
+func ( q *waitQueue ) enqueue( x interface{} ) {
+	q.queue.enqueue( x )
+	q.notEmpty.notify()
+}
+
+func ( q *waitQueue ) tryEnqueue( x interface{} ) bool {
+	if !tryEnqueue( q.queue, x ) {
+		return false
+	}
+	q.notEmpty.notify()
+
+	return true
+}
+
+func ( q *waitQueue ) enqueueCtx( ctx context.Context, x interface{} ) error {
+	if err := enqueueCtx( ctx, q.queue, x ); err != nil {
+		return err
+	}
+	q.notEmpty.notify()
+
+	return nil
+}
+
+func ( q *waitQueue ) enqueueBatch( xs []interface{} ) {
+	enqueueBatch( q.queue, xs )
+	q.notEmpty.notify()
+}
+
+func ( q *waitQueue ) dequeueBatch( dst []interface{} ) int {
+	return dequeueBatch( q.queue, dst )
+}
+
+func ( q *waitQueue ) dequeue() ( x interface{}, ok bool ) {
+	return q.queue.dequeue()
+}
+
+func ( q *waitQueue ) unwrap() interfaceQueue {
+	return q.queue
+}
+
+// dequeueCtx dequeues an element,
+// waiting until one is available, the queue is closed, or ctx is done.
+// In the latter cases, ErrClosed or ctx.Err() is returned, respectively.
+// Elements remaining in a closed queue are still returned.
+// An element is never removed from the queue without being returned.
+func ( q *waitQueue ) dequeueCtx( ctx context.Context ) ( interface{}, error ) {
+	for {
+		if x, err := tryDequeue( q.queue ); err != ErrEmpty {
+			return x, err
+		}
+		ch := q.notEmpty.prepare()
+		if x, err := tryDequeue( q.queue ); err != ErrEmpty {
+			return x, err
+		}
+		// Closing the queue and finishing the last pending
+		// enqueue operation afterwards both notify notEmpty
+		// (see closeQueue.leave), so there is no need to select on done,
+		// which would spin while enqueue operations are pending.
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// dequeueTimeout dequeues an element,
+// waiting at most for the given duration until one is available.
+// The error is nil on success, and as for dequeueCtx otherwise,
+// with context.DeadlineExceeded indicating the timeout.
+func ( q *waitQueue ) dequeueTimeout( timeout time.Duration ) ( interface{}, error ) {
+	if x, err := tryDequeue( q.queue ); ( err != ErrEmpty ) || ( timeout <= 0 ) {
+		return x, err
+	}
+	ctx, cancel := context.WithTimeout( context.Background(), timeout )
+	defer cancel()
+
+	return q.dequeueCtx( ctx )
+}
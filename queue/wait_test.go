@@ -0,0 +1,406 @@
+/*
+Copyright (c) 2017 Alexander Klauer
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package queue
+
+import(
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitingGoroutines counts the registrations of waiting goroutines
+// (see waitHook).
+var waitingGoroutines int32
+
+func init() {
+	waitHook = func() {
+		atomic.AddInt32( &waitingGoroutines, 1 )
+	}
+}
+
+// startWaiting runs f in a new goroutine
+// and returns once the goroutine has registered as a waiter.
+func startWaiting( t *testing.T, f func() ) {
+	before := atomic.LoadInt32( &waitingGoroutines )
+	go f()
+	deadline := time.Now().Add( 5 * time.Second )
+	for atomic.LoadInt32( &waitingGoroutines ) == before {
+		if time.Now().After( deadline ) {
+			t.Fatal( "Goroutine did not start waiting" )
+		}
+		runtime.Gosched()
+	}
+}
+
+// waitTestQueue is used to test blocking dequeues.
+type waitTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	DequeueWait func() int `queue:"dequeueWait"`
+	DequeueCtx func( context.Context ) ( int, error ) `queue:"dequeueCtx"`
+	DequeueTimeout func( time.Duration ) ( int, bool ) `queue:"dequeueTimeout"`
+}
+
+func TestDequeueWaitNonConcurrent( t *testing.T ) {
+	var q waitTestQueue
+	if err := Make( &q, DefaultConfig().NonConcurrent() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.Enqueue( 42 )
+	if x := q.DequeueWait(); x != 42 {
+		t.Errorf( "DequeueWait returned %d instead of 42", x )
+	}
+	defer func() {
+		if r := recover(); r != ErrEmpty {
+			t.Errorf( "DequeueWait on empty queue did not panic with ErrEmpty: %v", r )
+		}
+	}()
+	q.DequeueWait()
+}
+
+func TestDequeueWaitBlocks( t *testing.T ) {
+	for _, config := range filterConfigs( allConfigs( 16 ), concurrentConfig, unfailingConfig ) {
+		var q waitTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		var dequeued int32
+		done := make( chan int )
+		startWaiting( t, func() {
+			x := q.DequeueWait()
+			atomic.StoreInt32( &dequeued, 1 )
+			done <- x
+		} )
+		if atomic.LoadInt32( &dequeued ) != 0 {
+			t.Error( "DequeueWait returned on empty queue" )
+		}
+		q.Enqueue( 42 )
+		select {
+		case x := <-done:
+			if x != 42 {
+				t.Errorf( "DequeueWait returned %d instead of 42", x )
+			}
+		case <-time.After( 5 * time.Second ):
+			t.Fatalf( "DequeueWait not woken up by Enqueue with flags %x", config.Flags )
+		}
+	}
+}
+
+func TestDequeueWaitParallel( t *testing.T ) {
+	const writers = 4
+	const readers = 4
+	const iterations = 2000
+	for _, config := range filterConfigs( allConfigs( 16 ), concurrentConfig, unfailingConfig ) {
+		nreaders := readers
+		nwriters := writers
+		if ( config.Flags & FMultiReader ) == 0 {
+			nreaders = 1
+		}
+		if ( config.Flags & FMultiWriter ) == 0 {
+			nwriters = 1
+		}
+		var q waitTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		total := nwriters * iterations
+		seen := make( []int32, total )
+		var wg sync.WaitGroup
+		for w := 0; w < nwriters; w++ {
+			wg.Add( 1 )
+			go func( id int ) {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					q.Enqueue( id * iterations + i )
+				}
+			}( w )
+		}
+		for r := 0; r < nreaders; r++ {
+			wg.Add( 1 )
+			go func( id int ) {
+				defer wg.Done()
+				for i := id; i < total; i += nreaders {
+					atomic.AddInt32( &seen[q.DequeueWait()], 1 )
+				}
+			}( r )
+		}
+		wg.Wait()
+		for i, n := range seen {
+			if n != 1 {
+				t.Errorf( "Element %d seen %d times with flags %x", i, n, config.Flags )
+			}
+		}
+	}
+}
+
+func TestDequeueCtxNonConcurrent( t *testing.T ) {
+	var q waitTestQueue
+	if err := Make( &q, DefaultConfig().NonConcurrent() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.Enqueue( 42 )
+	if x, err := q.DequeueCtx( context.Background() ); ( x != 42 ) || ( err != nil ) {
+		t.Errorf( "DequeueCtx returned %d, %v instead of 42, nil", x, err )
+	}
+	if x, err := q.DequeueCtx( context.Background() ); ( x != 0 ) || ( err != ErrEmpty ) {
+		t.Errorf( "DequeueCtx on empty queue returned %d, %v instead of 0, ErrEmpty", x, err )
+	}
+}
+
+func TestDequeueCtxCancel( t *testing.T ) {
+	for _, config := range filterConfigs( allConfigs( 16 ), concurrentConfig, unfailingConfig ) {
+		var q waitTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		ctx, cancel := context.WithCancel( context.Background() )
+		done := make( chan error )
+		startWaiting( t, func() {
+			_, err := q.DequeueCtx( ctx )
+			done <- err
+		} )
+		cancel()
+		select {
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf( "DequeueCtx returned %v instead of context.Canceled", err )
+			}
+		case <-time.After( 5 * time.Second ):
+			t.Fatalf( "DequeueCtx not woken up by cancellation with flags %x", config.Flags )
+		}
+		// Elements are delivered even with a done context
+		q.Enqueue( 42 )
+		if x, err := q.DequeueCtx( ctx ); ( x != 42 ) || ( err != nil ) {
+			t.Errorf( "DequeueCtx returned %d, %v instead of 42, nil", x, err )
+		}
+	}
+}
+
+func TestDequeueCtxRace( t *testing.T ) {
+	const iterations = 500
+	// timeout bounds the test if an element is lost.
+	const timeout = 10 * time.Second
+	for _, config := range filterConfigs( allConfigs( 16 ), concurrentConfig, unfailingConfig ) {
+		var q waitTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		seen := make( []int32, iterations )
+		done := make( chan struct{} )
+		go func() {
+			defer close( done )
+			for i := 0; i < iterations; i++ {
+				q.Enqueue( i )
+				if i % 50 == 0 {
+					time.Sleep( time.Microsecond )
+				}
+			}
+		}()
+		deadline := time.Now().Add( timeout )
+		received := 0
+		for received < iterations {
+			if time.Now().After( deadline ) {
+				t.Fatalf( "Received only %d of %d elements with flags %x", received, iterations, config.Flags )
+			}
+			ctx, cancel := context.WithTimeout( context.Background(), time.Duration( received % 5 ) * time.Microsecond )
+			x, err := q.DequeueCtx( ctx )
+			cancel()
+			if err != nil {
+				continue
+			}
+			seen[x]++
+			received++
+		}
+		<-done
+		if _, ok := q.Dequeue(); ok {
+			t.Error( "Queue not empty after receiving all elements" )
+		}
+		for i, n := range seen {
+			if n != 1 {
+				t.Errorf( "Element %d seen %d times with flags %x", i, n, config.Flags )
+			}
+		}
+	}
+}
+
+func TestDequeueTimeoutNonConcurrent( t *testing.T ) {
+	var q waitTestQueue
+	if err := Make( &q, DefaultConfig().NonConcurrent() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	q.Enqueue( 42 )
+	if x, ok := q.DequeueTimeout( time.Hour ); ( x != 42 ) || !ok {
+		t.Errorf( "DequeueTimeout returned %d, %t instead of 42, true", x, ok )
+	}
+	if x, ok := q.DequeueTimeout( time.Hour ); ( x != 0 ) || ok {
+		t.Errorf( "DequeueTimeout on empty queue returned %d, %t instead of 0, false", x, ok )
+	}
+}
+
+func TestDequeueTimeout( t *testing.T ) {
+	const timeout = 20 * time.Millisecond
+	for _, config := range filterConfigs( allConfigs( 16 ), concurrentConfig, unfailingConfig ) {
+		var q waitTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+		// Timeout expires
+		start := time.Now()
+		if x, ok := q.DequeueTimeout( timeout ); ( x != 0 ) || ok {
+			t.Errorf( "DequeueTimeout on empty queue returned %d, %t instead of 0, false", x, ok )
+		}
+		if elapsed := time.Since( start ); elapsed < timeout {
+			t.Errorf( "DequeueTimeout returned after %s, expected at least %s", elapsed, timeout )
+		}
+		// Non-positive timeout does not wait
+		if _, ok := q.DequeueTimeout( 0 ); ok {
+			t.Error( "DequeueTimeout with zero timeout succeeds on empty queue" )
+		}
+		// Element arrives in time
+		go func() {
+			time.Sleep( timeout )
+			q.Enqueue( 42 )
+		}()
+		if x, ok := q.DequeueTimeout( 5 * time.Second ); ( x != 42 ) || !ok {
+			t.Errorf( "DequeueTimeout returned %d, %t instead of 42, true with flags %x", x, ok, config.Flags )
+		}
+	}
+}
+
+// readyTestQueue is used to test readiness channels.
+type readyTestQueue struct {
+	Enqueue func( int ) `queue:"enqueue"`
+	Dequeue func() ( int, bool ) `queue:"dequeue"`
+	Ready func() <-chan struct{} `queue:"ready"`
+	NotFull func() <-chan struct{} `queue:"notFull"`
+	Close func() `queue:"close"`
+}
+
+// isReady reports whether ch is closed,
+// allowing plenty of time for slow machines.
+// Channels which must not be ready are checked with isClosed,
+// as nothing can close them while the test does not act.
+func isReady( ch <-chan struct{} ) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After( 5 * time.Second ):
+		return false
+	}
+}
+
+func TestReady( t *testing.T ) {
+	for _, config := range filterConfigs( allConfigs( 16 ), concurrentConfig, unfailingConfig ) {
+		var q readyTestQueue
+		if err := Make( &q, config ); err != nil {
+			t.Fatalf( "Make failed with flags %x: %s", config.Flags, err )
+		}
+		ch := q.Ready()
+		if isClosed( ch ) {
+			t.Errorf( "Empty queue ready with flags %x", config.Flags )
+		}
+		go q.Enqueue( 1 )
+		if !isReady( ch ) {
+			t.Errorf( "Ready channel not closed after enqueue with flags %x", config.Flags )
+		}
+		if !isReady( q.Ready() ) {
+			t.Errorf( "Non-empty queue not ready with flags %x", config.Flags )
+		}
+		q.Dequeue()
+		ch = q.Ready()
+		q.Close()
+		if !isReady( ch ) {
+			t.Errorf( "Ready channel not closed after close with flags %x", config.Flags )
+		}
+		if !isReady( q.Ready() ) {
+			t.Errorf( "Closed queue not ready with flags %x", config.Flags )
+		}
+	}
+}
+
+func TestReadyNonConcurrent( t *testing.T ) {
+	var q readyTestQueue
+	if err := Make( &q, DefaultConfig().NonConcurrent() ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	if !isReady( q.Ready() ) {
+		t.Error( "Empty non-concurrent queue not ready" )
+	}
+	q.Enqueue( 1 )
+	if !isReady( q.Ready() ) {
+		t.Error( "Non-empty non-concurrent queue not ready" )
+	}
+	q.Dequeue()
+	q.Close()
+	if !isReady( q.Ready() ) {
+		t.Error( "Closed non-concurrent queue not ready" )
+	}
+	var bq readyTestQueue
+	if err := Make( &bq, DefaultConfig().NonConcurrent().Bounded( 1 ) ); err != nil {
+		t.Fatalf( "Make failed: %s", err )
+	}
+	bq.Enqueue( 1 )
+	if !isReady( bq.NotFull() ) {
+		t.Error( "Full non-concurrent queue not ready for writers" )
+	}
+}
+
+func TestReadySelect( t *testing.T ) {
+	const iterations = 1000
+	var q1, q2 readyTestQueue
+	for _, q := range []*readyTestQueue{ &q1, &q2 } {
+		if err := Make( q, DefaultConfig() ); err != nil {
+			t.Fatalf( "Make failed: %s", err )
+		}
+	}
+	go func() {
+		for i := 0; i < iterations; i++ {
+			q1.Enqueue( i )
+			q2.Enqueue( i )
+		}
+	}()
+	var next [2]int
+	for next[0] + next[1] < 2 * iterations {
+		select {
+		case <-q1.Ready():
+			if x, ok := q1.Dequeue(); ok {
+				if x != next[0] {
+					t.Fatalf( "Dequeue returned %d instead of %d", x, next[0] )
+				}
+				next[0]++
+			}
+		case <-q2.Ready():
+			if x, ok := q2.Dequeue(); ok {
+				if x != next[1] {
+					t.Fatalf( "Dequeue returned %d instead of %d", x, next[1] )
+				}
+				next[1]++
+			}
+		case <-time.After( 5 * time.Second ):
+			t.Fatal( "Ready channels not closed" )
+		}
+	}
+}